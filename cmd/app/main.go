@@ -1,261 +1,282 @@
 package main
 
 import (
+	"L0/internal/cache"
 	"L0/internal/config"
+	"L0/internal/consumer"
+	"L0/internal/dlq"
+	"L0/internal/health"
 	"L0/internal/httpapi"
+	"L0/internal/lifecycle"
+	"L0/internal/logging"
+	"L0/internal/metrics"
 	"L0/internal/model"
+	"L0/internal/outbox"
+	"L0/internal/refdata"
+	"L0/internal/repository"
+	"L0/internal/retry"
+	"L0/internal/tracing"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
 	_ "github.com/lib/pq"
 	kafka "github.com/segmentio/kafka-go"
 )
 
-type task struct {
-	value []byte
-	key   []byte
-
-	msg kafka.Message // исходное Kafka-сообщение для коммита
-}
-
 func main() {
+	if err := run(); err != nil {
+		slog.Error("сервис завершился с ошибкой", logging.Err(err))
+		os.Exit(1)
+	}
+	slog.Info("сервис остановлен")
+}
 
+func run() error {
 	cfg := config.MustLoad()
-	// канал для завершения канала чтения из кафки
-	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, syscall.SIGINT, syscall.SIGTERM) // для сигнала от системы о звершении
-
-	///////////////////////настройка консьюмера
-	r := kafka.NewReader(kafka.ReaderConfig{
-		Brokers: cfg.KafkaBrokers,
-		Topic:   cfg.KafkaTopic,
-		GroupID: cfg.KafkaGroupID,
-
-		CommitInterval: 0, // 0 = коммитим вручную через CommitMessages
 
-		// Таймауты сессии и ребалансов
-		HeartbeatInterval: 3 * time.Second,
-		SessionTimeout:    30 * time.Second,
-		RebalanceTimeout:  30 * time.Second,
-	})
-
-	defer r.Close()
-	log.Printf("Консьюмер подписан на топик '%s' в группе '%s'\n\n", cfg.KafkaTopic, cfg.KafkaGroupID)
-	ctx, cancel := context.WithCancel(context.Background())
-
-	////////////////настройка бд
-	configPg, err := pgxpool.ParseConfig(cfg.PostgresDSN)
+	logger, err := logging.New(os.Stderr, cfg.LogLevel, cfg.LogFormat)
 	if err != nil {
-		log.Fatal("Ошибка конфигурации пула: ", err)
+		return fmt.Errorf("config: %w", err)
 	}
+	// стандартный log (в том числе из сторонних библиотек) тоже идёт через slog
+	slog.SetDefault(logger)
 
-	configPg.MaxConns = 10 // Устанавливаем максимальное число соединений в пуле
-	configPg.MinConns = 2
-	configPg.MaxConnIdleTime = 5 * time.Minute
+	// сигнал от системы о завершении отменяет ctx
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ctx = logging.WithLogger(ctx, logger)
 
-	pool, err := pgxpool.NewWithConfig(ctx, configPg)
-	if err != nil {
-		log.Fatal("Ошибка создания пула: ", err)
+	// app migrate up|down [N]|status — только миграции, без запуска сервиса
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return runMigrate(ctx, cfg, os.Args[2:])
 	}
-	defer pool.Close()
-	log.Println("Пул соединений успешно настроен")
-
-	//////////////////параллельно пишем в бд
-	tasks := make(chan task, cfg.QueueSize)
-	acks := make(chan kafka.Message, cfg.QueueSize)
-	var wg sync.WaitGroup
-
-	wg.Add(cfg.Workers)
-	for i := range cfg.Workers {
-		go func(id int) {
-			defer wg.Done()
-			for t := range tasks {
-				log.Printf("горутина %d приступает к парсингу сообщения с id = %s:\n", id, t.key)
-				ctxDb, cancelDb := context.WithTimeout(ctx, cfg.RequestTimeout)
-				err := parsJsonToDB(ctxDb, pool, t.value)
-				cancelDb()
-				if err != nil {
-					log.Printf("ошибка записи cooбщения id = %s в бд: %v\n", t.key, err)
-					continue
-				}
-				acks <- t.msg
-			}
-		}(i + 1)
+	// app offsets reset ... — перемотка consumer group для повторной обработки
+	if len(os.Args) > 1 && os.Args[1] == "offsets" {
+		return runOffsets(ctx, cfg, os.Args[2:])
 	}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case m, ok := <-acks:
-				if !ok {
-					return
-				}
-				if err := r.CommitMessages(ctx, m); err != nil {
-					log.Printf("ошибка CommitMessages: %v", err)
-				}
-			}
+	////////////////трассировка
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Options{
+		ServiceName: "l0-app",
+		Exporter:    cfg.TracingExporter,
+		File:        cfg.TracingFile,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// досылаем накопленные спаны уже после остановки всех компонентов
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("не удалось дослать спаны", logging.Err(err))
 		}
 	}()
-	/////////////читаем из кафки и кладем в канал
-	wg.Add(1)
-	go readMsgs(ctx, r, tasks, &wg)
-
-	wg.Add(1)
-	go httpapi.Run(pool, cfg.HTTPAddr)
-	wg.Done()
-
-	<-signalChan // пришел сигнал о завершении
-
-	fmt.Println("закрываю канал")
-
-	close(tasks) // воркеры
-	close(acks)  // коммиттер
-
-	fmt.Println("отменяю контекст") // reader/fetch
-	cancel()
-
-	fmt.Println("закрываю горутины")
-	wg.Wait()
-
-	fmt.Println("стопаю сигн")
-	signal.Stop(signalChan)
-
-}
-
-func readMsgs(ctx context.Context, r *kafka.Reader, out chan<- task, wg *sync.WaitGroup) {
-	defer wg.Done()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		m, err := r.FetchMessage(ctx)
-		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				return
-			}
-			log.Printf("ошибка FetchMessage из Kafka: %v", err)
-			continue
-		}
+	////////////////настройка бд
+	pool, err := newPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	logger.Info("пул соединений настроен", "max_conns", pool.Config().MaxConns)
 
-		if !json.Valid(m.Value) {
-			log.Printf("пропуск невалидного JSON: partition=%d offset=%d", m.Partition, m.Offset)
-			if err := r.CommitMessages(ctx, m); err != nil {
-				log.Printf("ошибка CommitMessages (bad json): %v", err)
-			}
-			continue
+	if cfg.MigrateOnStart {
+		// реплики, стартующие одновременно, ждут друг друга на advisory-локе
+		if err := migrateUp(ctx, pool); err != nil {
+			return err
 		}
-
-		out <- task{value: m.Value, key: m.Key, msg: m}
 	}
-}
 
-func parsJsonToDB(ctx context.Context, pool *pgxpool.Pool, data []byte) error {
-	var o model.Order
+	////////////////dead-letter топик для отвергнутых сообщений
+	dead := dlq.New(cfg.KafkaBrokers, cfg.KafkaDLQTopic)
+	defer dead.Close()
+	logger.Info("отвергнутые сообщения уходят в DLQ", "topic", cfg.KafkaDLQTopic)
+
+	////////////////репозиторий и кеш
+	orders := cache.NewLRU[string, model.Order](cache.Options[model.Order]{
+		MaxEntries: cfg.CacheMaxEntries,
+		MaxBytes:   cfg.CacheMaxBytes,
+		TTL:        cfg.CacheTTL,
+		SizeOf:     repository.OrderSize,
+	})
+	repo := repository.New(pool, orders)
+	if cfg.NotFoundCacheTTL > 0 {
+		// отрицательный кеш: сканеры случайных UUID не должны долбить Postgres
+		repo.NotFound = cache.NewLRU[string, struct{}](cache.Options[struct{}]{
+			MaxEntries: 100000,
+			TTL:        cfg.NotFoundCacheTTL,
+		})
+	}
+	repo.Policy, err = repository.ParseConflictPolicy(cfg.ConflictPolicy)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
 
-	// парсинг JSON
-	if err := json.Unmarshal(data, &o); err != nil {
-		return fmt.Errorf("bad JSON: %w", err)
+	////////////////outbox: события о записанных заказах для других сервисов
+	var relay *outbox.Relay
+	if cfg.OutboxEnabled {
+		repo.Outbox = true
+		relay = outbox.New(pool, cfg.KafkaBrokers, outbox.Options{
+			Topic:        cfg.OutboxTopic,
+			PollInterval: cfg.OutboxPollInterval,
+			BatchSize:    cfg.OutboxBatchSize,
+			Retention:    cfg.OutboxRetention,
+			MaxAttempts:  cfg.OutboxMaxAttempts,
+			Retry: retry.Policy{
+				BaseDelay: cfg.RetryBaseDelay,
+				MaxDelay:  cfg.RetryMaxDelay,
+				Jitter:    cfg.RetryJitter,
+			},
+		})
+		defer relay.Close()
+		logger.Info("события о заказах публикуются через outbox", "topic", cfg.OutboxTopic)
 	}
-	log.Printf("JSON id = %s успешно распаршен\n", o.OrderUID)
 
-	if err := o.Validate(); err != nil {
-		log.Printf("невалидный заказ (%s): %v", o.OrderUID, err)
-		return fmt.Errorf("validate JSON failed: %w", err)
+	///////////////////////настройка консьюмера
+	group := &consumer.GroupWatcher{} // по логам kafka-go видно, состоит ли ридер в группе
+	kafkaErrors := kafka.LoggerFunc(func(format string, args ...any) {
+		logger.Warn(fmt.Sprintf(format, args...), logging.KeyComponent, "kafka-go")
+	})
+	var src consumer.Source
+	switch cfg.KafkaOffsetStore {
+	case "postgres":
+		// позиции партиций — в kafka_offsets, пишутся вместе с заказами
+		repo.OffsetGroup = cfg.KafkaGroupID
+		ar, err := consumer.NewAssignedReader(kafka.ConsumerGroupConfig{
+			ID:      cfg.KafkaGroupID,
+			Brokers: cfg.KafkaBrokers,
+			Topics:  []string{cfg.KafkaTopic},
+
+			HeartbeatInterval: 3 * time.Second,
+			SessionTimeout:    30 * time.Second,
+			RebalanceTimeout:  30 * time.Second,
+
+			Logger:      group,
+			ErrorLogger: kafkaErrors,
+		}, repo, logger.With(logging.KeyComponent, "kafka-reader"))
+		if err != nil {
+			return fmt.Errorf("kafka: %w", err)
+		}
+		defer ar.Close()
+		src = ar
+	default:
+		r := kafka.NewReader(kafka.ReaderConfig{
+			Brokers: cfg.KafkaBrokers,
+			Topic:   cfg.KafkaTopic,
+			GroupID: cfg.KafkaGroupID,
+
+			CommitInterval: 0, // 0 = коммитим вручную через CommitMessages
+
+			// Таймауты сессии и ребалансов
+			HeartbeatInterval: 3 * time.Second,
+			SessionTimeout:    30 * time.Second,
+			RebalanceTimeout:  30 * time.Second,
+
+			Logger:      group,
+			ErrorLogger: kafkaErrors,
+		})
+		defer r.Close()
+		src = r
 	}
-	// дочерним таблицам проставляем id
-	o.Delivery.OrderID = o.OrderUID
-	o.Payment.OrderID = o.OrderUID
-	for i := range o.Items {
-		o.Items[i].OrderID = o.OrderUID
+	logger.Info("консьюмер подписан на топик", "topic", cfg.KafkaTopic, "group", cfg.KafkaGroupID,
+		"offset_store", cfg.KafkaOffsetStore)
+
+	////////////////метрики: пул и кеши снимаются при каждом scrape /metrics
+	metrics.Register(
+		metrics.NewPoolCollector(pool),
+		metrics.NewCacheCollector("orders", orders.Stats),
+	)
+	if repo.NotFound != nil {
+		metrics.Register(metrics.NewCacheCollector("not_found", repo.NotFound.Stats))
 	}
-	log.Printf("Дочерним таблицам проставлен id = %s\n", o.OrderUID)
 
-	//начало транзакции
-	tx, err := pool.Begin(ctx)
+	financeMode, err := model.ParseFinanceMode(cfg.FinanceCheckMode)
 	if err != nil {
-		return err
+		return fmt.Errorf("config: %w", err)
 	}
-	defer tx.Rollback(ctx)
-	log.Printf("\t Начата транзакция для id = %s\n", o.OrderUID)
-
-	//  UPSERT orders по order_uid
-	if _, err := tx.Exec(ctx, `
-		INSERT INTO orders (
-			order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-	`,
-		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
-		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
-	); err != nil {
-		return fmt.Errorf("orders upsert: %w", err)
+	if !refdata.IsCountry(cfg.DefaultCountry) {
+		return fmt.Errorf("config: unknown DEFAULT_COUNTRY %q", cfg.DefaultCountry)
 	}
-	log.Printf("\tСообщение с id = %s (orders upsert) отправлено в бд\n", o.OrderUID)
-
-	// UPSERT deliveries (1:1, нужен UNIQUE(order_id))
-	if _, err := tx.Exec(ctx, `
-		INSERT INTO deliveries (order_id, name, phone, zip, city, address, region, email)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-	`,
-		o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
-		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
-	); err != nil {
-		return fmt.Errorf("deliveries upsert: %w", err)
+	rules := model.Rules{
+		FinanceMode:    financeMode,
+		Tolerance:      cfg.FinanceTolerance,
+		DefaultCountry: cfg.DefaultCountry,
 	}
-	log.Printf("Сообщение с id = %s (deliveries upsert) отправлено в бд\n", o.OrderUID)
-
-	// UPSERT payments по UNIQUE(transaction)
-	if _, err := tx.Exec(ctx, `
-		INSERT INTO payments (
-			order_id, transaction, request_id, currency, provider, amount, payment_dt,
-			bank, delivery_cost, goods_total, custom_fee
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-	`,
-		o.OrderUID, o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
-		o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank, o.Payment.DeliveryCost, o.Payment.GoodsTotal, o.Payment.CustomFee,
-	); err != nil {
-		return fmt.Errorf("payments upsert: %w", err)
+	if cfg.ReferenceAllowedFile != "" {
+		if rules.Allowed, err = refdata.LoadAllowed(cfg.ReferenceAllowedFile); err != nil {
+			return fmt.Errorf("reference allowed values: %w", err)
+		}
+		logger.Info("справочник допустимых значений загружен", "file", cfg.ReferenceAllowedFile)
 	}
-	log.Printf("Сообщение с id = %s (payments upsert) отправлено в бд\n", o.OrderUID)
 
-	// UPSERT order_items по UNIQUE(order_id, chrt_id, rid)
-	for _, it := range o.Items {
-		if _, err := tx.Exec(ctx, `
-			INSERT INTO order_items (
-				order_id, chrt_id, track_number, price, rid, name, sale, size,
-				total_price, nm_id, brand, status
-			) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-		`,
-			o.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale, it.Size,
-			it.TotalPrice, it.NmID, it.Brand, it.Status,
-		); err != nil {
-			return fmt.Errorf("items upsert: %w", err)
+	//////////////////компоненты сервиса
+	cons := consumer.New(src, repo, dead, consumer.Options{
+		Workers:        cfg.Workers,
+		QueueSize:      cfg.QueueSize,
+		RequestTimeout: cfg.RequestTimeout,
+		BatchSize:      cfg.BatchSize,
+		BatchLinger:    cfg.BatchLinger,
+		OffsetsInDB:    cfg.KafkaOffsetStore == "postgres",
+		Retry: retry.Policy{
+			MaxAttempts: cfg.RetryMaxAttempts,
+			BaseDelay:   cfg.RetryBaseDelay,
+			MaxDelay:    cfg.RetryMaxDelay,
+			Jitter:      cfg.RetryJitter,
+		},
+		Rules: rules,
+	})
+
+	////////////////проверки здоровья: /healthz, /readyz, /health/details
+	warmed := health.NewFlag("cache warm-up is not finished")
+	checker := health.New(cfg.HealthCheckTimeout)
+	checker.Add("postgres", pool.Ping)
+	checker.Add("kafka-brokers", consumer.PingBrokers(cfg.KafkaBrokers))
+	checker.Add("kafka-group", group.Check)
+	checker.Add("cache-warmup", warmed.Check)
+
+	srv := httpapi.NewServer(repo, checker, rules, cfg.HTTPAddr)
+
+	// запуск от зависимостей к зависящим, остановка — в обратном порядке:
+	// чтение из Kafka → дописать начатое в БД → закоммитить оффсеты → outbox → HTTP
+	mgr := lifecycle.New(cfg.ShutdownTimeout)
+	mgr.Add(lifecycle.Component{Name: "http", Run: srv.Run, Stop: srv.Stop})
+	// прогрев кеша: HTTP уже отвечает на /healthz, но /readyz ждёт его окончания,
+	// чтобы после деплоя трафик не бил в БД на каждый запрос
+	warmDone := make(chan struct{})
+	mgr.Add(lifecycle.Task("cache-warmup", func(ctx context.Context) error {
+		defer close(warmDone)
+		warmCtx, cancelWarm := context.WithTimeout(ctx, cfg.CacheWarmupTimeout)
+		defer cancelWarm()
+		warmLimit := cfg.CacheWarmupLimit
+		if cfg.CacheMaxEntries > 0 {
+			warmLimit = min(warmLimit, cfg.CacheMaxEntries) // больше всё равно вытеснится
 		}
+		if _, err := repo.WarmUp(warmCtx, warmLimit); err != nil {
+			logging.FromContext(ctx).Warn("прогрев кеша прерван, продолжаю с частичным кешем", logging.Err(err))
+		}
+		st := orders.Stats()
+		logging.FromContext(ctx).Info("кеш готов", "entries", st.Entries, "bytes", st.Bytes)
+		warmed.Set()
+		return nil
+	}))
+	// ретранслятор останавливается после консьюмера и публикует его последние события
+	if relay != nil {
+		mgr.Add(relay.Component())
 	}
-	log.Printf("Сообщение с id = %s (items upsert) отправлено в бд\n", o.OrderUID)
-
-	// commit
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit: %w", err)
+	// консьюмер ждёт конца прогрева: иначе прогрев мог бы положить в кеш
+	// версию заказа старше той, что воркер только что записал
+	for _, c := range cons.Components() {
+		mgr.Add(lifecycle.After(warmDone, c))
 	}
-	// конец транзакции
-	log.Printf("\tТранзакция для id = %s завершена\n", o.OrderUID)
-	return nil
+
+	return mgr.Run(ctx)
 }
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"L0/internal/config"
+	"L0/internal/logging"
+	"L0/internal/migrate"
+	"L0/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func newPool(ctx context.Context, cfg config.Config) (*pgxpool.Pool, error) {
+	configPg, err := pgxpool.ParseConfig(cfg.PostgresDSN)
+	if err != nil {
+		return nil, fmt.Errorf("postgres config: %w", err)
+	}
+
+	configPg.MaxConns = 10 // Устанавливаем максимальное число соединений в пуле
+	configPg.MinConns = 2
+	configPg.MaxConnIdleTime = 5 * time.Minute
+	configPg.ConnConfig.Tracer = tracing.QueryTracer{} // спан на каждый запрос внутри трассы
+
+	pool, err := pgxpool.NewWithConfig(ctx, configPg)
+	if err != nil {
+		return nil, fmt.Errorf("postgres pool: %w", err)
+	}
+	return pool, nil
+}
+
+const migrateUsage = "usage: app migrate up | down [N] | status"
+
+// runMigrate — подкоманда migrate: up применяет все новые миграции,
+// down откатывает N последних (по умолчанию одну), status печатает таблицу.
+func runMigrate(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	pool, err := newPool(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+
+	switch args[0] {
+	case "up":
+		return migrateUp(ctx, pool)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return errors.New("migrate down: N must be a positive number")
+			}
+		}
+		m, err := migrate.New(pool)
+		if err != nil {
+			return err
+		}
+		reverted, err := m.Down(ctx, steps)
+		for _, mig := range reverted {
+			logging.FromContext(ctx).Info("миграция откачена", "version", mig.Version, "name", mig.Name)
+		}
+		if err == nil && len(reverted) == 0 {
+			logging.FromContext(ctx).Info("нет применённых миграций")
+		}
+		return err
+
+	case "status":
+		m, err := migrate.New(pool)
+		if err != nil {
+			return err
+		}
+		st, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range st {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func migrateUp(ctx context.Context, pool *pgxpool.Pool) error {
+	m, err := migrate.New(pool)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(ctx)
+	for _, mig := range applied {
+		logging.FromContext(ctx).Info("миграция применена", "version", mig.Version, "name", mig.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+	if len(applied) == 0 {
+		logging.FromContext(ctx).Info("схема БД актуальна")
+	}
+	return nil
+}
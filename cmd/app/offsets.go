@@ -0,0 +1,137 @@
+package main
+
+import (
+	"L0/internal/config"
+	"L0/internal/consumer"
+	"L0/internal/logging"
+	"L0/internal/repository"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const offsetsUsage = "usage: app offsets reset [--dry-run] [--partitions 0,1,...] earliest | latest | <offset> | <time>"
+
+// runOffsets — подкоманда offsets reset: перематывает группу KAFKA_GROUP_ID
+// по KAFKA_TOPIC, чтобы заново прочитать (или пропустить) часть сообщений.
+// --dry-run только печатает план. При KAFKA_OFFSET_STORE=postgres позиции
+// перезаписываются и в kafka_offsets: консьюмер читает с них.
+func runOffsets(ctx context.Context, cfg config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "reset" {
+		return errors.New(offsetsUsage)
+	}
+	fs := flag.NewFlagSet("offsets reset", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	dryRun := fs.Bool("dry-run", false, "")
+	only := fs.String("partitions", "", "")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 1 {
+		return errors.New(offsetsUsage)
+	}
+	target, err := consumer.ParseSeekTarget(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	partitions, err := parsePartitions(*only)
+	if err != nil {
+		return err
+	}
+
+	seeker := consumer.NewSeeker(cfg.KafkaBrokers, cfg.KafkaGroupID, cfg.KafkaTopic)
+	plan, err := seeker.Plan(ctx, target, partitions)
+	if err != nil {
+		return err
+	}
+
+	var repo *repository.Repository
+	if cfg.KafkaOffsetStore == "postgres" {
+		pool, err := newPool(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+		repo = repository.New(pool, nil)
+		repo.OffsetGroup = cfg.KafkaGroupID
+
+		// консьюмер начинает с позиции из БД, коммит Kafka — только если её нет
+		stored, err := repo.LoadOffsets(ctx, cfg.KafkaTopic)
+		if err != nil {
+			return err
+		}
+		for i, p := range plan {
+			if next, ok := stored[p.Partition]; ok {
+				plan[i].Current = next
+			}
+		}
+	}
+
+	fmt.Printf("group %s, topic %s, offsets in %s, target %s\n",
+		cfg.KafkaGroupID, cfg.KafkaTopic, cfg.KafkaOffsetStore, target)
+	if err := printSeekPlan(os.Stdout, plan); err != nil {
+		return err
+	}
+
+	lg := logging.FromContext(ctx)
+	if *dryRun {
+		if n, err := seeker.ActiveMembers(ctx); err == nil && n > 0 {
+			lg.Warn("в группе есть активные консьюмеры: перед перемоткой их нужно остановить", "members", n)
+		}
+		return nil
+	}
+
+	if err := seeker.Commit(ctx, plan); err != nil {
+		return err
+	}
+	if repo != nil {
+		next := make(map[int]int64, len(plan))
+		for _, p := range plan {
+			next[p.Partition] = p.Target
+		}
+		if err := repo.ResetOffsets(ctx, cfg.KafkaTopic, next); err != nil {
+			return fmt.Errorf("kafka offsets are reset, but postgres ones are not: %w", err)
+		}
+	}
+	lg.Info("группа перемотана", "group", cfg.KafkaGroupID, "topic", cfg.KafkaTopic,
+		"partitions", len(plan), "target", target.String())
+	return nil
+}
+
+// parsePartitions разбирает список партиций "0,2,5"; пусто — все.
+func parsePartitions(v string) ([]int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	var out []int
+	for _, s := range strings.Split(v, ",") {
+		p, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("partitions: %q is not a partition number", s)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func printSeekPlan(w io.Writer, plan []consumer.PartitionSeek) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "PARTITION\tCURRENT\tTARGET\tLOG START\tLOG END\tLAG\tREPROCESS\tSKIP\t")
+	var lag, reprocess, skip int64
+	for _, p := range plan {
+		current := "-"
+		if p.Current >= 0 {
+			current = strconv.FormatInt(p.Current, 10)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			p.Partition, current, p.Target, p.Start, p.End, p.Lag(), p.Reprocess(), p.Skip())
+		lag += p.Lag()
+		reprocess += p.Reprocess()
+		skip += p.Skip()
+	}
+	fmt.Fprintf(tw, "total\t\t\t\t\t%d\t%d\t%d\t\n", lag, reprocess, skip)
+	return tw.Flush()
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"L0/internal/config"
 	"L0/internal/model"
+	"L0/internal/tracing"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/brianvoe/gofakeit/v7"
 	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -19,6 +21,20 @@ const (
 
 func main() {
 	cfg := config.MustLoad()
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "l0-producer",
+		Exporter:    cfg.TracingExporter,
+		File:        cfg.TracingFile,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	w := &kafka.Writer{
 		Addr:     kafka.TCP(cfg.KafkaBrokers...),
 		Topic:    cfg.KafkaTopic,
@@ -36,11 +52,24 @@ func main() {
 		if err := sendMsgToKafka(w, order); err != nil {
 			continue
 		}
+		for _, u := range genStatusUpdates(order) {
+			if err := sendStatusToKafka(w, u); err != nil {
+				break
+			}
+		}
 	}
 
 	fmt.Println("Producer finished.")
 }
-func sendMsgToKafka(w *kafka.Writer, order model.Order) error {
+func sendMsgToKafka(w *kafka.Writer, order model.Order) (err error) {
+	// корневой спан трассы заказа; консьюмер продолжит его по заголовкам сообщения
+	ctx, span := tracing.Start(context.Background(), "kafka.publish",
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", w.Topic),
+		attribute.String("messaging.kafka.message.key", order.OrderUID),
+	)
+	defer func() { tracing.End(span, err) }()
+
 	orderJson, err := json.Marshal(order)
 	if err != nil {
 		log.Fatalf("marshal: %v", err)
@@ -53,8 +82,9 @@ func sendMsgToKafka(w *kafka.Writer, order model.Order) error {
 		Value: []byte(orderJson),
 		Time:  time.Now(),
 	}
+	tracing.Inject(ctx, &msg)
 
-	errWrite := w.WriteMessages(context.Background(), msg)
+	errWrite := w.WriteMessages(ctx, msg)
 	if errWrite != nil {
 		log.Printf("Ошибка отправки сообщения в Кафку id: '%s': %v\n", order.OrderUID, errWrite)
 		return errWrite
@@ -63,21 +93,74 @@ func sendMsgToKafka(w *kafka.Writer, order model.Order) error {
 	}
 	return nil
 }
+
+// sendStatusToKafka отправляет смену статуса в тот же топик с тем же ключом,
+// что и заказ: так событие попадает в ту же партицию и идёт после заказа.
+func sendStatusToKafka(w *kafka.Writer, u model.StatusUpdate) (err error) {
+	ctx, span := tracing.Start(context.Background(), "kafka.publish",
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", w.Topic),
+		attribute.String("messaging.kafka.message.key", u.OrderUID),
+		attribute.String("order.status", string(u.Status)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	msg := kafka.Message{
+		Key:     []byte(u.OrderUID),
+		Value:   data,
+		Time:    time.Now(),
+		Headers: []kafka.Header{{Key: model.HeaderMessageType, Value: []byte(model.MessageTypeStatus)}},
+	}
+	tracing.Inject(ctx, &msg)
+
+	if err := w.WriteMessages(ctx, msg); err != nil {
+		log.Printf("Ошибка отправки статуса в Кафку id: '%s' → %s: %v\n", u.OrderUID, u.Status, err)
+		return err
+	}
+	log.Printf("Отправлен статус:\tid: '%s' → %s\n", u.OrderUID, u.Status)
+	return nil
+}
+
+// genStatusUpdates — случайный путь заказа по жизненному циклу от created:
+// от нуля до нескольких допустимых переходов.
+func genStatusUpdates(order model.Order) []model.StatusUpdate {
+	var out []model.StatusUpdate
+	st := model.StatusCreated
+	at := order.DateCreated
+	for steps := gofakeit.Number(0, 4); steps > 0; steps-- {
+		next := st.Next()
+		if len(next) == 0 {
+			break
+		}
+		// отмена и возврат — редкость: основной путь идёт первым в списке
+		st = next[0]
+		if len(next) > 1 && gofakeit.Number(1, 10) == 1 {
+			st = next[1]
+		}
+		at = at.Add(time.Duration(gofakeit.Number(1, 120)) * time.Minute)
+		out = append(out, model.StatusUpdate{OrderUID: order.OrderUID, Status: st, ChangedAt: at})
+	}
+	return out
+}
+
 func genOrder() model.Order {
 	orderID := gofakeit.UUID()
 	track := fmt.Sprintf("WB%s", gofakeit.LetterN(10))
 
 	nItems := gofakeit.Number(1, 3)
 	items := make([]model.Item, 0, nItems)
-	var goodsTotal int
+	currency := gofakeit.RandomString([]string{"RUB", "USD", "EUR"})
+	goodsTotal := model.Money{Currency: currency}
 
 	for j := 0; j < nItems; j++ {
-		price := gofakeit.Number(200, 5000)
+		// суммы считаются в минимальных единицах валюты, как их сверяет консьюмер
+		price := model.NewMoney(int64(gofakeit.Number(200, 5000)), currency)
 		sale := gofakeit.Number(0, 50)
-		total := price - price*sale/100
-		if total < 0 {
-			total = 0
-		}
+		total := model.Money{Minor: price.Minor - price.Minor*int64(sale)/100, Currency: currency}
 
 		item := model.Item{
 			OrderID:     orderID,
@@ -91,15 +174,15 @@ func genOrder() model.Order {
 			TotalPrice:  total,
 			NmID:        int64(gofakeit.Number(100000, 9999999)),
 			Brand:       gofakeit.Company(),
-			Status:      202,
+			Status:      model.ItemCreated,
 		}
 		items = append(items, item)
-		goodsTotal += total
+		goodsTotal = goodsTotal.Add(total)
 	}
 
-	deliveryCost := gofakeit.Number(0, 2000)
-	customFee := 0
-	amountInt := goodsTotal + deliveryCost + customFee
+	deliveryCost := model.NewMoney(int64(gofakeit.Number(0, 2000)), currency)
+	customFee := model.Money{Currency: currency}
+	amount := goodsTotal.Add(deliveryCost).Add(customFee)
 
 	return model.Order{
 		OrderUID:    orderID,
@@ -109,8 +192,8 @@ func genOrder() model.Order {
 		Delivery: model.Delivery{
 			OrderID: orderID,
 			Name:    gofakeit.Name(),
-			Phone:   gofakeit.Phone(),
-			Zip:     gofakeit.Zip(),
+			Phone:   "+7" + gofakeit.Numerify("9#########"), // E.164, DEFAULT_COUNTRY=RU
+			Zip:     gofakeit.Numerify("######"),
 			City:    gofakeit.City(),
 			Address: gofakeit.Address().Address,
 			Region:  gofakeit.State(),
@@ -121,9 +204,9 @@ func genOrder() model.Order {
 			OrderID:      orderID,
 			Transaction:  gofakeit.UUID(),
 			RequestID:    "",
-			Currency:     gofakeit.RandomString([]string{"RUB", "USD", "EUR"}),
+			Currency:     currency,
 			Provider:     gofakeit.RandomString([]string{"wbpay", "bank", "visa", "mc"}),
-			Amount:       float64(amountInt),
+			Amount:       amount,
 			PaymentDT:    time.Now().Unix(),
 			Bank:         gofakeit.RandomString([]string{"alpha", "sber", "tinkoff", "vtb"}),
 			DeliveryCost: deliveryCost,
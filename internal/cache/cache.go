@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache — кеш, которым пользуется репозиторий. Реализации обязаны быть
+// безопасными для конкурентного использования.
+type Cache[K comparable, V any] interface {
+	Get(key K) (V, bool)
+	Set(key K, v V)
+	Delete(key K)
+	Len() int
+	Stats() Stats
+}
+
+// Stats — снимок состояния кеша.
+type Stats struct {
+	Entries     int
+	Bytes       int64 // приблизительный объём, если задан SizeOf
+	Hits        uint64
+	Misses      uint64
+	Evictions   uint64 // вытеснено по лимиту записей/байт
+	Expirations uint64 // удалено по TTL
+}
+
+// Options — ограничения LRU. Нулевое значение поля — без ограничения.
+type Options[V any] struct {
+	MaxEntries int
+	MaxBytes   int64
+	TTL        time.Duration
+	SizeOf     func(V) int64 // оценка размера записи, нужна для MaxBytes
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	val     V
+	size    int64
+	expires time.Time     // zero — бессрочно
+	byExp   *list.Element // место в LRU.exp, если задан TTL
+}
+
+// LRU — кеш с вытеснением давно неиспользуемых записей по числу записей
+// и приблизительному объёму, с необязательным TTL на запись.
+type LRU[K comparable, V any] struct {
+	opts Options[V]
+
+	mu    sync.Mutex
+	ll    *list.List // спереди — самые свежие
+	exp   *list.List // по сроку: спереди — раньше всех устаревающие (TTL у всех один)
+	items map[K]*list.Element
+	bytes int64
+	stats Stats
+}
+
+var _ Cache[string, int] = (*LRU[string, int])(nil)
+
+func NewLRU[K comparable, V any](opts Options[V]) *LRU[K, V] {
+	return &LRU[K, V]{
+		opts:  opts,
+		ll:    list.New(),
+		exp:   list.New(),
+		items: make(map[K]*list.Element),
+	}
+}
+
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		c.removeElement(el)
+		c.stats.Expirations++
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.ll.MoveToFront(el)
+	c.stats.Hits++
+	return e.val, true
+}
+
+func (c *LRU[K, V]) Set(key K, v V) {
+	var size int64
+	if c.opts.SizeOf != nil {
+		size = c.opts.SizeOf(v)
+	}
+	var expires time.Time
+	if c.opts.TTL > 0 {
+		expires = time.Now().Add(c.opts.TTL)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// запись больше всего лимита не кладём, чтобы не выметать ради неё весь кеш
+	if c.opts.MaxBytes > 0 && size > c.opts.MaxBytes {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+		}
+		return
+	}
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		c.bytes += size - e.size
+		e.val, e.size, e.expires = v, size, expires
+		c.ll.MoveToFront(el)
+		if e.byExp != nil {
+			c.exp.MoveToBack(e.byExp)
+		}
+	} else {
+		e := &entry[K, V]{key: key, val: v, size: size, expires: expires}
+		if !expires.IsZero() {
+			e.byExp = c.exp.PushBack(e)
+		}
+		c.items[key] = c.ll.PushFront(e)
+		c.bytes += size
+	}
+
+	// устаревшие уходят раньше живых, даже если их давно не читали
+	c.expire()
+	for c.overLimit() {
+		c.removeElement(c.ll.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *LRU[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *LRU[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expire()
+	return c.ll.Len()
+}
+
+func (c *LRU[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expire()
+	s := c.stats
+	s.Entries = c.ll.Len()
+	s.Bytes = c.bytes
+	return s
+}
+
+func (c *LRU[K, V]) overLimit() bool {
+	if c.ll.Len() == 0 {
+		return false
+	}
+	if c.opts.MaxEntries > 0 && c.ll.Len() > c.opts.MaxEntries {
+		return true
+	}
+	return c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes
+}
+
+func (c *LRU[K, V]) removeElement(el *list.Element) {
+	e := el.Value.(*entry[K, V])
+	c.ll.Remove(el)
+	if e.byExp != nil {
+		c.exp.Remove(e.byExp)
+	}
+	delete(c.items, e.key)
+	c.bytes -= e.size
+}
+
+// expire удаляет устаревшие записи. Срок у всех записей отсчитывается от
+// Set с одним TTL, поэтому они устаревают в порядке c.exp.
+func (c *LRU[K, V]) expire() {
+	now := time.Now()
+	for front := c.exp.Front(); front != nil; front = c.exp.Front() {
+		e := front.Value.(*entry[K, V])
+		if !now.After(e.expires) {
+			return
+		}
+		c.removeElement(c.items[e.key])
+		c.stats.Expirations++
+	}
+}
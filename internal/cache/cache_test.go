@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+type op struct {
+	del bool // Delete вместо Set
+	get bool // Get вместо Set
+	key string
+	val string
+}
+
+func set(k, v string) op { return op{key: k, val: v} }
+func get(k string) op    { return op{get: true, key: k} }
+func del(k string) op    { return op{del: true, key: k} }
+
+func TestLRU(t *testing.T) {
+	size := func(v string) int64 { return int64(len(v)) }
+
+	tests := []struct {
+		name  string
+		opts  Options[string]
+		ops   []op
+		keys  []string // оставшиеся ключи
+		stats Stats
+	}{
+		{
+			name:  "no limits",
+			ops:   []op{set("a", "1"), set("b", "2"), set("c", "3")},
+			keys:  []string{"a", "b", "c"},
+			stats: Stats{Entries: 3},
+		},
+		{
+			name:  "evicts least recently set by entries",
+			opts:  Options[string]{MaxEntries: 2},
+			ops:   []op{set("a", "1"), set("b", "2"), set("c", "3")},
+			keys:  []string{"b", "c"},
+			stats: Stats{Entries: 2, Evictions: 1},
+		},
+		{
+			name:  "get refreshes recency",
+			opts:  Options[string]{MaxEntries: 2},
+			ops:   []op{set("a", "1"), set("b", "2"), get("a"), set("c", "3")},
+			keys:  []string{"a", "c"},
+			stats: Stats{Entries: 2, Hits: 1, Evictions: 1},
+		},
+		{
+			name:  "overwrite does not evict",
+			opts:  Options[string]{MaxEntries: 2},
+			ops:   []op{set("a", "1"), set("b", "2"), set("a", "3")},
+			keys:  []string{"a", "b"},
+			stats: Stats{Entries: 2},
+		},
+		{
+			name:  "evicts by bytes",
+			opts:  Options[string]{MaxBytes: 6, SizeOf: size},
+			ops:   []op{set("a", "aaa"), set("b", "bb"), set("c", "cc")},
+			keys:  []string{"b", "c"},
+			stats: Stats{Entries: 2, Bytes: 4, Evictions: 1},
+		},
+		{
+			name:  "evicts several for one large entry",
+			opts:  Options[string]{MaxBytes: 6, SizeOf: size},
+			ops:   []op{set("a", "aa"), set("b", "bb"), set("c", "bb"), set("d", "dddd")},
+			keys:  []string{"c", "d"},
+			stats: Stats{Entries: 2, Bytes: 6, Evictions: 2},
+		},
+		{
+			name:  "grown overwrite is accounted",
+			opts:  Options[string]{MaxBytes: 6, SizeOf: size},
+			ops:   []op{set("a", "aa"), set("b", "bb"), set("a", "aaaaa")},
+			keys:  []string{"a"},
+			stats: Stats{Entries: 1, Bytes: 5, Evictions: 1},
+		},
+		{
+			name:  "entry over the whole limit is not stored",
+			opts:  Options[string]{MaxBytes: 4, SizeOf: size},
+			ops:   []op{set("a", "aa"), set("b", "bbbbb")},
+			keys:  []string{"a"},
+			stats: Stats{Entries: 1, Bytes: 2},
+		},
+		{
+			name:  "entry over the whole limit drops the old value",
+			opts:  Options[string]{MaxBytes: 4, SizeOf: size},
+			ops:   []op{set("a", "aa"), set("a", "aaaaa")},
+			keys:  nil,
+			stats: Stats{},
+		},
+		{
+			name:  "delete",
+			opts:  Options[string]{SizeOf: size},
+			ops:   []op{set("a", "aa"), set("b", "bbb"), del("a"), del("missing")},
+			keys:  []string{"b"},
+			stats: Stats{Entries: 1, Bytes: 3},
+		},
+		{
+			name:  "get after delete misses",
+			ops:   []op{set("a", "1"), del("a"), get("a")},
+			keys:  nil,
+			stats: Stats{Misses: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewLRU[string, string](tt.opts)
+			for _, o := range tt.ops {
+				switch {
+				case o.get:
+					c.Get(o.key)
+				case o.del:
+					c.Delete(o.key)
+				default:
+					c.Set(o.key, o.val)
+				}
+			}
+			if got := keys(c); !slices.Equal(got, tt.keys) {
+				t.Errorf("keys = %v, want %v", got, tt.keys)
+			}
+			if got := c.Stats(); got != tt.stats {
+				t.Errorf("stats = %+v, want %+v", got, tt.stats)
+			}
+		})
+	}
+}
+
+func TestLRUTTL(t *testing.T) {
+	c := NewLRU[string, string](Options[string]{TTL: 20 * time.Millisecond})
+	c.Set("a", "1")
+	if v, ok := c.Get("a"); !ok || v != "1" {
+		t.Fatalf("Get before TTL = %q, %v; want \"1\", true", v, ok)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	c.Set("b", "2") // свежая запись не устаревает вместе со старой
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get after TTL: entry is still there")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("fresh entry expired")
+	}
+	want := Stats{Entries: 1, Hits: 2, Misses: 1, Expirations: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
+
+// Устаревшая запись, которую больше не читают, не занимает место живых и
+// не попадает в Stats.
+func TestLRUExpiresUnread(t *testing.T) {
+	c := NewLRU[string, string](Options[string]{MaxEntries: 2, TTL: 20 * time.Millisecond})
+	c.Set("a", "1")
+	c.Set("b", "2")
+	if _, ok := c.Get("a"); !ok { // свежепрочитанная запись устаревает в свой срок
+		t.Fatal("entry expired before TTL")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if got, want := c.Stats(), (Stats{Hits: 1, Expirations: 2}); got != want {
+		t.Errorf("stats after TTL = %+v, want %+v", got, want)
+	}
+
+	c.Set("c", "3")
+	c.Set("d", "4")
+	if got := keys(c); !slices.Equal(got, []string{"c", "d"}) {
+		t.Errorf("keys = %v, want [c d]", got)
+	}
+	if got := c.Stats(); got.Evictions != 0 {
+		t.Errorf("evictions = %d, want 0: live entries were evicted instead of expired ones", got.Evictions)
+	}
+}
+
+// keys — ключи кеша, не трогая статистику и порядок вытеснения, по
+// возрастанию.
+func keys(c *LRU[string, string]) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []string
+	for k := range c.items {
+		out = append(out, k)
+	}
+	slices.Sort(out)
+	return out
+}
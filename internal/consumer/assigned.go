@@ -0,0 +1,191 @@
+package consumer
+
+import (
+	"L0/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// OffsetLoader отдаёт сохранённые в БД позиции чтения топика:
+// партиция → первый ещё не применённый оффсет.
+type OffsetLoader interface {
+	LoadOffsets(ctx context.Context, topic string) (map[int]int64, error)
+}
+
+// AssignedReader читает топик в consumer group, но позицию каждой
+// назначенной партиции берёт из БД, а не из коммитов Kafka: на каждое
+// поколение группы открывается по ридеру на партицию, и он перематывается
+// на сохранённый оффсет. Коммиты в Kafka остаются только для мониторинга
+// отставания и инструментов группы.
+type AssignedReader struct {
+	cfg     kafka.ConsumerGroupConfig
+	topic   string
+	group   *kafka.ConsumerGroup
+	offsets OffsetLoader
+	lg      *slog.Logger
+
+	msgs chan kafka.Message
+	gen  atomic.Pointer[kafka.Generation] // текущее поколение, для коммитов
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ Source = (*AssignedReader)(nil)
+
+// NewAssignedReader вступает в группу cfg.ID; cfg.Topics — ровно один топик.
+func NewAssignedReader(cfg kafka.ConsumerGroupConfig, offsets OffsetLoader, lg *slog.Logger) (*AssignedReader, error) {
+	if len(cfg.Topics) != 1 {
+		return nil, fmt.Errorf("assigned reader: want one topic, got %d", len(cfg.Topics))
+	}
+	group, err := kafka.NewConsumerGroup(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("consumer group: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &AssignedReader{
+		cfg:     cfg,
+		topic:   cfg.Topics[0],
+		group:   group,
+		offsets: offsets,
+		lg:      lg,
+		msgs:    make(chan kafka.Message),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go a.run(ctx)
+	return a, nil
+}
+
+func (a *AssignedReader) run(ctx context.Context) {
+	defer close(a.done)
+	for {
+		gen, err := a.group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return
+			}
+			a.lg.Error("ошибка вступления в consumer group", logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(fetchErrorDelay):
+			}
+			continue
+		}
+		a.gen.Store(gen)
+
+		parts := gen.Assignments[a.topic]
+		a.lg.Info("назначены партиции", "generation", gen.ID, "partitions", len(parts))
+		for _, p := range parts {
+			gen.Start(func(ctx context.Context) { a.readPartition(ctx, p) })
+		}
+	}
+}
+
+// readPartition читает одну партицию, пока живо поколение группы.
+func (a *AssignedReader) readPartition(ctx context.Context, p kafka.PartitionAssignment) {
+	lg := a.lg.With(logging.KeyPartition, p.ID)
+	offset, err := a.startOffset(ctx, p)
+	if err != nil {
+		return // поколение закончилось
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     a.cfg.Brokers,
+		Topic:       a.topic,
+		Partition:   p.ID,
+		Dialer:      a.cfg.Dialer,
+		ErrorLogger: a.cfg.ErrorLogger,
+	})
+	defer r.Close()
+	if err := r.SetOffset(offset); err != nil {
+		lg.Error("не удалось перемотать партицию", logging.KeyOffset, offset, logging.Err(err))
+		return
+	}
+	lg.Info("партиция читается с сохранённой позиции", logging.KeyOffset, offset)
+
+	for {
+		m, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			lg.Error("ошибка чтения партиции", logging.Err(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(fetchErrorDelay):
+			}
+			continue
+		}
+		select {
+		case a.msgs <- m:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// startOffset — позиция из БД, а если партиция там ещё не записана, то
+// из назначения группы (коммит в Kafka или StartOffset). БД недоступна —
+// ждём её: читать с позиции Kafka значило бы применить что-то дважды.
+func (a *AssignedReader) startOffset(ctx context.Context, p kafka.PartitionAssignment) (int64, error) {
+	for {
+		stored, err := a.offsets.LoadOffsets(ctx, a.topic)
+		if err == nil {
+			if next, ok := stored[p.ID]; ok {
+				return next, nil
+			}
+			return p.Offset, nil
+		}
+		a.lg.Error("не удалось прочитать позиции из БД", logging.KeyPartition, p.ID, logging.Err(err))
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(fetchErrorDelay):
+		}
+	}
+}
+
+func (a *AssignedReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-a.msgs:
+		return m, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	case <-a.done:
+		return kafka.Message{}, io.EOF
+	}
+}
+
+// CommitMessages коммитит оффсеты в Kafka через текущее поколение группы.
+func (a *AssignedReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	gen := a.gen.Load()
+	if gen == nil {
+		return errors.New("consumer group has no generation yet")
+	}
+	offsets := make(map[string]map[int]int64)
+	for _, m := range msgs {
+		if offsets[m.Topic] == nil {
+			offsets[m.Topic] = make(map[int]int64)
+		}
+		offsets[m.Topic][m.Partition] = m.Offset + 1
+	}
+	return gen.CommitOffsets(offsets)
+}
+
+// Close выходит из группы и останавливает чтение партиций.
+func (a *AssignedReader) Close() error {
+	a.cancel()
+	err := a.group.Close()
+	<-a.done
+	return err
+}
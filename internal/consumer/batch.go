@@ -0,0 +1,153 @@
+package consumer
+
+import (
+	"L0/internal/logging"
+	"L0/internal/metrics"
+	"L0/internal/model"
+	"L0/internal/tracing"
+	"context"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// batched — разобранный заказ, ждущий записи в составе пачки.
+type batched struct {
+	ctx   context.Context // с полями лога сообщения
+	span  trace.Span      // order.process, закрывает finish
+	t     task
+	order model.Order
+}
+
+// batchLoop — воркер пакетного режима: копит до BatchSize заказов, но не
+// дольше BatchLinger от первого, и пишет их одной транзакцией. Смена статуса,
+// повтор заказа из пачки и сообщение, которое уйдёт в DLQ, сначала
+// выталкивают накопленное: сообщения партиции применяются по порядку.
+func (c *Consumer) batchLoop(ctx context.Context, id int, lane <-chan task) {
+	var (
+		pending []batched
+		uids    = make(map[string]struct{}, c.opts.BatchSize)
+		timer   = time.NewTimer(c.opts.BatchLinger)
+		linger  <-chan time.Time // nil, пока пачка пуста
+	)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		timer.Stop()
+		linger = nil
+		if len(pending) > 0 {
+			for _, t := range c.flushBatch(ctx, id, pending) {
+				c.ack(ctx, t)
+			}
+		}
+		pending = pending[:0]
+		clear(uids)
+	}
+
+	for {
+		select {
+		case t, ok := <-lane:
+			if !ok {
+				flush()
+				return
+			}
+			metrics.QueueDepth.Set(c.queueDepth())
+
+			if messageType(t.m) == model.MessageTypeStatus {
+				// смена статуса применяется после заказов, прочитанных раньше неё
+				flush()
+				if c.process(ctx, id, t) {
+					c.ack(ctx, t)
+				}
+				continue
+			}
+
+			mctx, span := c.startMessage(ctx, id, t)
+			o, err := decodeOrder(mctx, c.opts.Rules, t.m.Value)
+			if err != nil {
+				// заказы, прочитанные раньше, пишем до того, как это сообщение
+				// уйдёт в DLQ: при оффсетах в БД его позиция сдвинула бы
+				// next_offset за них, и SaveBatch счёл бы их уже применёнными
+				flush()
+				if c.finish(mctx, span, t.m, 1, err) {
+					c.ack(ctx, t)
+				}
+				continue
+			}
+			if _, dup := uids[o.OrderUID]; dup {
+				// две версии одного заказа в одну пачку не сливаем — пишем по порядку
+				flush()
+			}
+			pending = append(pending, batched{ctx: mctx, span: span, t: t, order: o})
+			uids[o.OrderUID] = struct{}{}
+			if len(pending) == 1 {
+				timer.Reset(c.opts.BatchLinger)
+				linger = timer.C
+			}
+			if len(pending) >= c.opts.BatchSize {
+				flush()
+			}
+		case <-linger:
+			flush()
+		}
+	}
+}
+
+// flushBatch пишет пачку одной транзакцией. Не вышло — пишет заказы по
+// одному с обычными повторами и DLQ, чтобы один плохой заказ не топил
+// остальные. Возвращает сообщения, оффсеты которых можно коммитить.
+func (c *Consumer) flushBatch(ctx context.Context, id int, batch []batched) []task {
+	links := make([]trace.Link, 0, len(batch))
+	orders := make([]model.Order, 0, len(batch))
+	msgs := make([]kafka.Message, 0, len(batch))
+	for _, b := range batch {
+		links = append(links, trace.Link{SpanContext: b.span.SpanContext()})
+		orders = append(orders, b.order)
+		msgs = append(msgs, b.t.m)
+	}
+	bctx, span := tracing.StartLinked(ctx, "order.batch", links,
+		attribute.Int("batch.size", len(batch)), attribute.Int("worker.id", id))
+	lg := logging.FromContext(ctx).With(logging.KeyWorkerID, id, "batch_size", len(batch))
+
+	metrics.BatchSize.Observe(float64(len(batch)))
+	start := time.Now()
+	wctx, cancel := context.WithTimeout(c.withPositions(bctx, msgs...), c.opts.RequestTimeout)
+	err := c.repo.SaveBatch(wctx, orders)
+	cancel()
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.BatchDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	tracing.End(span, err)
+
+	done := make([]task, 0, len(batch))
+	if err == nil {
+		lg.Debug("пачка записана", "duration", time.Since(start))
+		for _, b := range batch {
+			if c.finish(b.ctx, b.span, b.t.m, 1, nil) {
+				done = append(done, b.t)
+			}
+		}
+		return done
+	}
+
+	if ctx.Err() == nil {
+		metrics.BatchFallbacks.Inc()
+		lg.Warn("пачка не записана, пишу заказы по одному", logging.Err(err))
+	}
+	// при остановке сервиса попытки сразу упадут, и finish оставит
+	// сообщения незакоммиченными
+	for _, b := range batch {
+		attempts, err := c.writeWithRetry(b.ctx, func(ctx context.Context) error {
+			return c.repo.SaveOrder(c.withPositions(ctx, b.t.m), b.order)
+		})
+		if c.finish(b.ctx, b.span, b.t.m, attempts, err) {
+			done = append(done, b.t)
+		}
+	}
+	return done
+}
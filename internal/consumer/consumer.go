@@ -0,0 +1,505 @@
+package consumer
+
+import (
+	"L0/internal/dlq"
+	"L0/internal/lifecycle"
+	"L0/internal/logging"
+	"L0/internal/metrics"
+	"L0/internal/model"
+	"L0/internal/repository"
+	"L0/internal/retry"
+	"L0/internal/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// fetchErrorDelay — пауза после ошибки FetchMessage, чтобы не крутить
+// пустой цикл, пока брокер недоступен.
+const fetchErrorDelay = time.Second
+
+// Source — откуда консьюмер читает сообщения и куда коммитит оффсеты:
+// *kafka.Reader с GroupID или AssignedReader.
+type Source interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+}
+
+type Options struct {
+	Workers        int           // по полосе на воркер
+	QueueSize      int           // на все полосы вместе
+	RequestTimeout time.Duration // на одну попытку записи в БД
+	Retry          retry.Policy
+	Rules          model.Rules   // проверка заказа перед записью
+	BatchSize      int           // заказов на одну транзакцию; <= 1 — пишем по одному
+	BatchLinger    time.Duration // сколько первый заказ пачки ждёт остальных
+	// оффсеты пишутся в Postgres той же транзакцией, что и данные
+	// (repository.WithPositions); полосы тогда делятся по партициям
+	OffsetsInDB bool
+}
+
+// Consumer — конвейер Kafka → полосы воркеров → коммит оффсетов. Сообщения
+// с одним ключом (order_uid) всегда попадают в одну полосу и обрабатываются
+// по порядку; коммиттер коммитит только непрерывно обработанные оффсеты.
+// Каждая стадия отдаётся отдельным lifecycle.Component; стадии связаны
+// каналами, и закрытие входного канала — сигнал следующей стадии дописать
+// своё и выйти:
+// остановка чтения → воркеры дописывают начатое → коммиттер коммитит.
+type Consumer struct {
+	r    Source
+	repo repository.OrderWriter
+	dead *dlq.Publisher
+	opts Options
+
+	lanes   []chan task    // прочитанные, ещё не обработанные; полоса на воркер
+	acks    chan task      // обработанные (в БД или в DLQ), можно коммитить
+	offsets *offsetTracker // выданные в работу, по партициям
+
+	fetchCtx  context.Context
+	stopFetch context.CancelFunc
+}
+
+func New(r Source, repo repository.OrderWriter, dead *dlq.Publisher, opts Options) *Consumer {
+	fetchCtx, stopFetch := context.WithCancel(context.Background())
+	lanes := make([]chan task, max(opts.Workers, 1))
+	for i := range lanes {
+		lanes[i] = make(chan task, max(opts.QueueSize/len(lanes), 1))
+	}
+	return &Consumer{
+		r:         r,
+		repo:      repo,
+		dead:      dead,
+		opts:      opts,
+		lanes:     lanes,
+		acks:      make(chan task, opts.QueueSize),
+		offsets:   newOffsetTracker(),
+		fetchCtx:  fetchCtx,
+		stopFetch: stopFetch,
+	}
+}
+
+// task — сообщение на пути от ридера к коммиттеру вместе с контекстом
+// трассы его спана kafka.receive: воркер и коммиттер продолжают трассу от
+// него, а заголовки сообщения остаются такими, какими их прислал продюсер
+// (с ними сообщение уходит и в DLQ).
+type task struct {
+	m     kafka.Message
+	trace trace.SpanContext
+}
+
+// traceCtx — ctx с родительским спаном kafka.receive.
+func (t task) traceCtx(ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(ctx, t.trace)
+}
+
+// laneOf выбирает полосу по ключу сообщения. Сообщения без ключа порядка
+// не требуют и раскладываются по оффсету. При оффсетах в БД партиция целиком
+// идёт в одну полосу: её оффсеты должны применяться строго по порядку, а
+// ключ всё равно определяет партицию.
+func (c *Consumer) laneOf(m kafka.Message) int {
+	n := len(c.lanes)
+	if c.opts.OffsetsInDB {
+		return m.Partition % n
+	}
+	if len(m.Key) == 0 {
+		return int(m.Offset % int64(n))
+	}
+	h := fnv.New32a()
+	h.Write(m.Key)
+	return int(h.Sum32() % uint32(n))
+}
+
+// queueDepth — сообщения во всех полосах, ещё не взятые воркерами.
+func (c *Consumer) queueDepth() float64 {
+	n := 0
+	for _, l := range c.lanes {
+		n += len(l)
+	}
+	return float64(n)
+}
+
+// Components возвращает стадии в порядке запуска для lifecycle.Manager:
+// коммиттер, воркеры, чтение. Останавливаются они в обратном порядке.
+func (c *Consumer) Components() []lifecycle.Component {
+	return []lifecycle.Component{
+		{Name: "kafka-committer", Run: c.runCommitter},
+		{Name: "workers", Run: c.runWorkers},
+		{Name: "kafka-reader", Run: c.runReader, Stop: func(context.Context) error {
+			c.stopFetch()
+			return nil
+		}},
+	}
+}
+
+func (c *Consumer) runReader(ctx context.Context) error {
+	// воркеры дорабатывают свои полосы и выходят
+	defer func() {
+		for _, l := range c.lanes {
+			close(l)
+		}
+	}()
+
+	fetchCtx, cancel := context.WithCancel(c.fetchCtx)
+	defer cancel()
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-fetchCtx.Done():
+		}
+	}()
+
+	for {
+		m, err := c.r.FetchMessage(fetchCtx)
+		if err != nil {
+			if fetchCtx.Err() != nil {
+				return nil
+			}
+			logging.FromContext(ctx).Error("ошибка чтения из Kafka", logging.Err(err))
+			select {
+			case <-fetchCtx.Done():
+				return nil
+			case <-time.After(fetchErrorDelay):
+			}
+			continue
+		}
+		metrics.MessagesFetched.Inc()
+		// HighWaterMark — оффсет следующего сообщения, которое появится в партиции
+		metrics.Lag.WithLabelValues(strconv.Itoa(m.Partition)).Set(float64(max(m.HighWaterMark-m.Offset-1, 0)))
+
+		// спан чтения продолжает трассу продюсера и длится, пока сообщение
+		// ждёт свободного места в очереди; дальше воркер и коммиттер
+		// продолжают уже его (task.trace)
+		_, span := tracing.Start(tracing.Extract(ctx, m), "kafka.receive", messageAttrs(m)...)
+		span.SetAttributes(attribute.Int64("messaging.kafka.produce_to_fetch_ms", time.Since(m.Time).Milliseconds()))
+
+		// до передачи воркеру: иначе коммиттер может отпустить следующие оффсеты раньше
+		c.offsets.track(m)
+		metrics.Uncommitted.WithLabelValues(strconv.Itoa(m.Partition)).Set(float64(c.offsets.pending(m.Topic, m.Partition)))
+		select {
+		case c.lanes[c.laneOf(m)] <- task{m: m, trace: span.SpanContext()}:
+			metrics.QueueDepth.Set(c.queueDepth())
+			span.End()
+		case <-fetchCtx.Done():
+			// не взятое в работу сообщение держит коммит партиции и будет перечитано
+			span.End()
+			return nil
+		}
+	}
+}
+
+func (c *Consumer) runWorkers(ctx context.Context) error {
+	defer close(c.acks) // коммиттер коммитит остаток и выходит
+
+	var wg sync.WaitGroup
+	for i, lane := range c.lanes {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			if c.opts.BatchSize > 1 {
+				c.batchLoop(ctx, id, lane)
+				return
+			}
+			for t := range lane {
+				metrics.QueueDepth.Set(c.queueDepth())
+				if c.process(ctx, id, t) {
+					c.ack(ctx, t)
+				}
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+	return nil
+}
+
+// ack передаёт обработанное сообщение коммиттеру.
+func (c *Consumer) ack(ctx context.Context, t task) {
+	select {
+	case c.acks <- t:
+	case <-ctx.Done():
+	}
+}
+
+// process записывает сообщение с повторами; при окончательной ошибке
+// перекладывает его в DLQ. true — оффсет можно коммитить.
+func (c *Consumer) process(ctx context.Context, id int, t task) bool {
+	ctx, span := c.startMessage(ctx, id, t)
+	attempts, err := c.writeWithRetry(ctx, func(ctx context.Context) error {
+		return c.handle(ctx, t.m)
+	})
+	return c.finish(ctx, span, t.m, attempts, err)
+}
+
+// startMessage открывает спан обработки сообщения и кладёт в контекст
+// поля лога; закрывает спан finish.
+func (c *Consumer) startMessage(ctx context.Context, id int, t task) (context.Context, trace.Span) {
+	m := t.m
+	ctx, span := tracing.Start(t.traceCtx(ctx), "order.process", messageAttrs(m)...)
+	ctx = logging.With(ctx,
+		logging.KeyOrderUID, string(m.Key), // продюсер кладёт order_uid в ключ
+		logging.KeyPartition, m.Partition,
+		logging.KeyOffset, m.Offset,
+		logging.KeyWorkerID, id,
+	)
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		ctx = logging.With(ctx, logging.KeyTraceID, sc.TraceID().String())
+	}
+	logging.FromContext(ctx).Debug("сообщение взято в работу")
+	return ctx, span
+}
+
+// writeWithRetry выполняет запись с повторами по c.opts.Retry, ограничивая
+// каждую попытку c.opts.RequestTimeout.
+func (c *Consumer) writeWithRetry(ctx context.Context, write func(ctx context.Context) error) (int, error) {
+	lg := logging.FromContext(ctx)
+	policy := c.opts.Retry
+	return retry.Do(ctx, policy, func(ctx context.Context, attempt int) (err error) {
+		ctx, span := tracing.Start(ctx, "order.attempt", attribute.Int("retry.attempt", attempt))
+		defer func() { tracing.End(span, err) }()
+
+		ctxDb, cancelDb := context.WithTimeout(ctx, c.opts.RequestTimeout)
+		defer cancelDb()
+		if attempt > 1 {
+			metrics.Retries.Inc()
+		}
+		start := time.Now()
+		err = write(ctxDb)
+		result := "ok"
+		if err != nil {
+			result = "error"
+		}
+		metrics.ProcessDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+		if err != nil && retry.IsRetriable(err) && attempt < policy.MaxAttempts {
+			lg.Warn("временная ошибка, повторю", "attempt", attempt, "max_attempts", policy.MaxAttempts, logging.Err(err))
+		}
+		return err
+	})
+}
+
+// finish подводит итог обработки сообщения и закрывает его спан: при
+// окончательной ошибке перекладывает сообщение в DLQ. true — оффсет можно
+// коммитить.
+func (c *Consumer) finish(ctx context.Context, span trace.Span, m kafka.Message, attempts int, err error) (ok bool) {
+	defer func() {
+		span.SetAttributes(attribute.Bool("order.committable", ok))
+		span.End()
+	}()
+
+	lg := logging.FromContext(ctx)
+	if err == nil {
+		// сколько прошло от отправки продюсером до момента, когда заказ можно прочитать
+		stored := time.Since(m.Time)
+		metrics.ProduceToStored.Observe(stored.Seconds())
+		span.SetAttributes(attribute.Int64("order.produce_to_stored_ms", stored.Milliseconds()))
+		lg.Info("сообщение обработано", "attempts", attempts, "produce_to_stored", stored)
+		return true
+	}
+	span.RecordError(err)
+	if ctx.Err() != nil {
+		// сервис останавливается принудительно: не коммитим, сообщение перечитается
+		lg.Warn("обработка прервана остановкой, сообщение будет перечитано", logging.Err(err))
+		return false
+	}
+
+	stage := dlq.StageOf(err)
+	metrics.MessagesFailed.WithLabelValues(string(stage)).Inc()
+	lg = lg.With(logging.KeyStage, string(stage))
+	lg.Error("сообщение не обработано", "attempts", attempts, logging.Err(err))
+	// оффсет коммитим только когда сообщение осело в DLQ
+	if err := c.deadLetter(ctx, m, err, attempts); err != nil {
+		lg.Warn("отправка в DLQ прервана остановкой, сообщение будет перечитано", logging.Err(err))
+		return false
+	}
+	metrics.MessagesDeadLettered.WithLabelValues(string(stage)).Inc()
+	lg.Info("сообщение отправлено в DLQ", "topic", c.dead.Topic())
+	if c.opts.OffsetsInDB {
+		if err := c.repo.StoreOffsets(ctx, position(m)); err != nil {
+			// не страшно: позицию за него сдвинет следующее сообщение партиции,
+			// а если рестарт будет раньше — оно уйдёт в DLQ ещё раз
+			lg.Warn("оффсет сообщения из DLQ не записан в БД", logging.Err(err))
+		}
+	}
+	return true
+}
+
+// deadLetter публикует сообщение в DLQ, повторяя неудачи с задержкой по
+// c.opts.Retry, пока не выйдет или не отменят ctx. Пропустить сообщение
+// нельзя: за ним встал бы коммит партиции (а при оффсетах в БД его обогнал
+// бы next_offset). Поэтому полоса стоит, её буфер заполняется, и ридер
+// перестаёт читать — это и есть обратное давление, пока DLQ недоступна.
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, cause error, attempts int) error {
+	lg := logging.FromContext(ctx)
+	for failures := 1; ; failures++ {
+		err := c.dead.Publish(ctx, m, cause, attempts)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		metrics.DeadLetterErrors.Inc()
+		// не чаще раза в fetchErrorDelay, даже при нулевой BaseDelay
+		delay := max(c.opts.Retry.Backoff(failures), fetchErrorDelay)
+		lg.Error("не удалось отправить сообщение в DLQ, повторю", "failures", failures, "retry_in", delay, logging.Err(err))
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Consumer) runCommitter(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case t, ok := <-c.acks:
+			if !ok {
+				return nil
+			}
+			m := t.m
+			// коммитим последний из непрерывно обработанных; сообщение,
+			// обогнавшее более раннее, ждёт его
+			offset, n := c.offsets.done(m)
+			metrics.Uncommitted.WithLabelValues(strconv.Itoa(m.Partition)).Set(float64(c.offsets.pending(m.Topic, m.Partition)))
+			if n == 0 {
+				continue
+			}
+			last := kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: offset}
+			// спан коммита — в трассе сообщения, чья обработка его отпустила
+			_, span := tracing.Start(t.traceCtx(ctx), "kafka.commit", messageAttrs(last)...)
+			span.SetAttributes(attribute.Int("messaging.batch.message_count", n))
+			err := c.r.CommitMessages(ctx, last)
+			tracing.End(span, err)
+			if err != nil {
+				// следующий коммит партиции покроет и эти оффсеты
+				logging.FromContext(ctx).Error("ошибка коммита оффсета",
+					logging.KeyPartition, last.Partition, logging.KeyOffset, last.Offset, logging.Err(err))
+				continue
+			}
+			metrics.MessagesCommitted.Add(float64(n))
+		}
+	}
+}
+
+// handle разбирает сообщение по типу из заголовка model.HeaderMessageType
+// и пишет его в БД.
+func (c *Consumer) handle(ctx context.Context, m kafka.Message) error {
+	ctx = c.withPositions(ctx, m)
+	if messageType(m) == model.MessageTypeStatus {
+		return applyStatus(ctx, c.repo, m.Value)
+	}
+	return parsJsonToDB(ctx, c.repo, c.opts.Rules, m.Value)
+}
+
+// withPositions кладёт в контекст записи позиции сообщений, если оффсеты
+// хранятся в БД.
+func (c *Consumer) withPositions(ctx context.Context, ms ...kafka.Message) context.Context {
+	if !c.opts.OffsetsInDB {
+		return ctx
+	}
+	ps := make([]repository.Position, 0, len(ms))
+	for _, m := range ms {
+		ps = append(ps, position(m))
+	}
+	return repository.WithPositions(ctx, ps...)
+}
+
+func position(m kafka.Message) repository.Position {
+	return repository.Position{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset}
+}
+
+func messageType(m kafka.Message) string {
+	for _, h := range m.Headers {
+		if h.Key == model.HeaderMessageType {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// applyStatus применяет событие смены статуса. Заказа ещё нет — ошибка
+// временная (заказ мог прийти с другим ключом или ещё не дочитан), запрещённый
+// переход — нет.
+func applyStatus(ctx context.Context, repo repository.OrderWriter, data []byte) error {
+	var u model.StatusUpdate
+
+	_, span := tracing.Start(ctx, "status.decode")
+	if err := json.Unmarshal(data, &u); err != nil {
+		err = dlq.WithStage(dlq.StageDecode, fmt.Errorf("bad JSON: %w", err))
+		tracing.End(span, err)
+		return err
+	}
+	span.End()
+
+	if err := u.Validate(); err != nil {
+		return dlq.WithStage(dlq.StageValidate, fmt.Errorf("validate status update failed: %w", err))
+	}
+
+	err := repo.UpdateStatus(ctx, u)
+	if errors.Is(err, model.ErrInvalidTransition) || errors.Is(err, repository.ErrNotFound) ||
+		errors.Is(err, repository.ErrAmbiguousItem) {
+		return dlq.WithStage(dlq.StageStatus, err)
+	}
+	return err
+}
+
+func parsJsonToDB(ctx context.Context, repo repository.OrderWriter, rules model.Rules, data []byte) error {
+	o, err := decodeOrder(ctx, rules, data)
+	if err != nil {
+		return err
+	}
+	return repo.SaveOrder(ctx, o)
+}
+
+// decodeOrder разбирает заказ из JSON и проверяет его по rules.
+func decodeOrder(ctx context.Context, rules model.Rules, data []byte) (model.Order, error) {
+	var o model.Order
+
+	// парсинг JSON
+	_, span := tracing.Start(ctx, "order.decode")
+	if err := json.Unmarshal(data, &o); err != nil {
+		err = dlq.WithStage(dlq.StageDecode, fmt.Errorf("bad JSON: %w", err))
+		tracing.End(span, err)
+		return o, err
+	}
+	span.End()
+
+	_, span = tracing.Start(ctx, "order.validate")
+	warnings, err := o.Check(rules)
+	if err != nil {
+		err = dlq.WithStage(dlq.StageValidate, fmt.Errorf("validate JSON failed: %w", err))
+		tracing.End(span, err)
+		return o, err
+	}
+	span.End()
+	if len(warnings) > 0 {
+		// режим warn: заказ принимаем, но расхождение сумм должно быть видно сверке
+		metrics.FinanceWarnings.Inc()
+		logging.FromContext(ctx).Warn("суммы заказа не сходятся", "violations", warnings)
+	}
+	return o, nil
+}
+
+// messageAttrs — атрибуты спана по семантике OpenTelemetry для сообщений.
+func messageAttrs(m kafka.Message) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.destination.name", m.Topic),
+		attribute.Int("messaging.destination.partition.id", m.Partition),
+		attribute.Int64("messaging.kafka.offset", m.Offset),
+		attribute.String("messaging.kafka.message.key", string(m.Key)),
+	}
+}
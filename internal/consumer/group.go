@@ -0,0 +1,63 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// GroupWatcher следит за членством ридера в consumer group. Другого способа
+// узнать это у kafka.Reader нет, поэтому он подключается как ReaderConfig.Logger
+// и ловит сообщения kafka-go о старте/остановке heartbeat: heartbeat идёт ровно
+// тогда, когда ридер состоит в группе и получил партиции.
+type GroupWatcher struct {
+	joined atomic.Bool
+}
+
+var _ kafka.Logger = (*GroupWatcher)(nil)
+
+func (w *GroupWatcher) Printf(format string, _ ...any) {
+	switch {
+	case strings.HasPrefix(format, "started heartbeat for group"):
+		w.joined.Store(true)
+	case strings.HasPrefix(format, "stopped heartbeat for group"):
+		w.joined.Store(false)
+	}
+}
+
+func (w *GroupWatcher) Joined() bool {
+	return w.joined.Load()
+}
+
+// Check — проверка готовности: ридер в группе.
+func (w *GroupWatcher) Check(context.Context) error {
+	if w.Joined() {
+		return nil
+	}
+	return errors.New("kafka reader has not joined the consumer group")
+}
+
+// PingBrokers — проверка доступности Kafka: достаточно одного живого брокера.
+func PingBrokers(brokers []string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, b := range brokers {
+			conn, err := kafka.DialContext(ctx, "tcp", b)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", b, err))
+				continue
+			}
+			_, err = conn.Brokers()
+			conn.Close()
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", b, err))
+		}
+		return errors.Join(errs...)
+	}
+}
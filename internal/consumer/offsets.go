@@ -0,0 +1,86 @@
+package consumer
+
+import (
+	"sort"
+	"sync"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// offsetTracker помнит по каждой партиции оффсеты сообщений, выданных
+// воркерам, и отпускает на коммит только непрерывный готовый префикс: пока
+// сообщение с меньшим оффсетом в работе (или не обработано), коммит за него
+// не уходит. Сами сообщения не хранятся: за застрявшим оффсетом может
+// накопиться много выданных.
+type offsetTracker struct {
+	mu    sync.Mutex
+	parts map[partitionKey][]trackedOffset // по возрастанию оффсетов
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+type trackedOffset struct {
+	offset int64
+	done   bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{parts: make(map[partitionKey][]trackedOffset)}
+}
+
+// track регистрирует прочитанное сообщение до передачи воркеру. Оффсет не
+// больше уже выданных значит, что ридер перечитывает партицию (ребаланс):
+// выданное с этого оффсета забываем — оно придёт заново.
+func (t *offsetTracker) track(m kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := partitionKey{m.Topic, m.Partition}
+	q := t.parts[k]
+	if n := len(q); n > 0 && m.Offset <= q[n-1].offset {
+		q = q[:t.search(q, m.Offset)]
+	}
+	t.parts[k] = append(q, trackedOffset{offset: m.Offset})
+}
+
+// done отмечает сообщение обработанным и возвращает последний оффсет
+// непрерывного готового префикса партиции и число отпущенных сообщений;
+// 0 — коммитить пока нечего.
+func (t *offsetTracker) done(m kafka.Message) (int64, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	k := partitionKey{m.Topic, m.Partition}
+	q := t.parts[k]
+	i := t.search(q, m.Offset)
+	if i == len(q) || q[i].offset != m.Offset {
+		// забыто после перечитывания партиции
+		return 0, 0
+	}
+	q[i].done = true
+
+	n := 0
+	for n < len(q) && q[n].done {
+		n++
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	last := q[n-1].offset
+	t.parts[k] = q[n:]
+	return last, n
+}
+
+// pending — сколько сообщений партиции выдано и ещё не отпущено на коммит.
+func (t *offsetTracker) pending(topic string, partition int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.parts[partitionKey{topic, partition}])
+}
+
+func (t *offsetTracker) search(q []trackedOffset, offset int64) int {
+	return sort.Search(len(q), func(i int) bool { return q[i].offset >= offset })
+}
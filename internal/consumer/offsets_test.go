@@ -0,0 +1,102 @@
+package consumer
+
+import (
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	type step struct {
+		track bool  // track вместо done
+		part  int   // партиция (по умолчанию 0)
+		off   int64 // оффсет сообщения
+		last  int64 // ожидаемый оффсет для коммита (для done)
+		n     int   // сколько отпущено на коммит (для done)
+	}
+	track := func(off int64) step { return step{track: true, off: off} }
+	done := func(off, last int64, n int) step { return step{off: off, last: last, n: n} }
+
+	tests := []struct {
+		name    string
+		steps   []step
+		pending int // осталось в партиции 0
+	}{
+		{
+			name:  "in order",
+			steps: []step{track(10), track(11), done(10, 10, 1), done(11, 11, 1)},
+		},
+		{
+			name: "out of order completion waits for the gap",
+			steps: []step{
+				track(10), track(11), track(12),
+				done(12, 0, 0), done(11, 0, 0),
+				done(10, 12, 3),
+			},
+		},
+		{
+			name:    "stuck offset holds the rest",
+			steps:   []step{track(10), track(11), track(12), done(11, 0, 0), done(12, 0, 0)},
+			pending: 3,
+		},
+		{
+			name: "gaps in offsets (compaction) do not block",
+			steps: []step{
+				track(10), track(15), track(20),
+				done(15, 0, 0), done(10, 15, 2), done(20, 20, 1),
+			},
+		},
+		{
+			name: "rewind forgets offsets from the re-read one",
+			steps: []step{
+				track(10), track(11), track(12),
+				track(11), // ребаланс: партиция перечитывается с 11
+				done(12, 0, 0),
+				done(10, 10, 1),
+				track(12),
+				done(11, 11, 1), done(12, 12, 1),
+			},
+		},
+		{
+			name: "forgotten offset is ignored",
+			steps: []step{
+				track(10), track(11),
+				track(10), // перечитывание: 11 забыт, пока его не выдадут снова
+				done(11, 0, 0),
+				done(10, 10, 1),
+			},
+		},
+		{
+			name:    "unknown offset is ignored",
+			steps:   []step{track(10), done(9, 0, 0), done(42, 0, 0)},
+			pending: 1,
+		},
+		{
+			name: "partitions are independent",
+			steps: []step{
+				track(10), {track: true, part: 1, off: 5},
+				{part: 1, off: 5, last: 5, n: 1},
+			},
+			pending: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newOffsetTracker()
+			for i, s := range tt.steps {
+				m := kafka.Message{Topic: "orders", Partition: s.part, Offset: s.off}
+				if s.track {
+					tr.track(m)
+					continue
+				}
+				last, n := tr.done(m)
+				if n != s.n || (n > 0 && last != s.last) {
+					t.Fatalf("step %d: done(%d) = %d, %d; want %d, %d", i, s.off, last, n, s.last, s.n)
+				}
+			}
+			if got := tr.pending("orders", 0); got != tt.pending {
+				t.Errorf("pending = %d, want %d", got, tt.pending)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// SeekTarget — куда перемотать consumer group в каждой партиции.
+type SeekTarget struct {
+	kind   string // "earliest", "latest", "offset" или "time"
+	offset int64
+	at     time.Time
+}
+
+// ParseSeekTarget разбирает цель перемотки: earliest, latest, оффсет
+// или момент времени (RFC 3339 или ГГГГ-ММ-ДД, UTC).
+func ParseSeekTarget(v string) (SeekTarget, error) {
+	switch v {
+	case "earliest", "latest":
+		return SeekTarget{kind: v}, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n < 0 {
+			return SeekTarget{}, errors.New("seek offset must not be negative")
+		}
+		return SeekTarget{kind: "offset", offset: n}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return SeekTarget{kind: "time", at: t}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return SeekTarget{kind: "time", at: t}, nil
+	}
+	return SeekTarget{}, fmt.Errorf("seek target %q: want earliest, latest, an offset or a time", v)
+}
+
+func (t SeekTarget) String() string {
+	switch t.kind {
+	case "offset":
+		return "offset " + strconv.FormatInt(t.offset, 10)
+	case "time":
+		return "time " + t.at.Format(time.RFC3339)
+	}
+	return t.kind
+}
+
+// PartitionSeek — план перемотки одной партиции. Все оффсеты — «следующий
+// к чтению», как в коммитах группы.
+type PartitionSeek struct {
+	Partition int
+	Current   int64 // позиция группы сейчас; -1 — группа партицию ещё не читала
+	Target    int64 // позиция после перемотки
+	Start     int64 // первый оффсет, ещё хранящийся в партиции
+	End       int64 // оффсет следующего сообщения (high watermark)
+}
+
+// Lag — сколько сообщений группа прочитает после перемотки.
+func (p PartitionSeek) Lag() int64 { return p.End - p.Target }
+
+// Reprocess — сколько уже обработанных сообщений будет прочитано заново.
+func (p PartitionSeek) Reprocess() int64 {
+	if p.Current < 0 {
+		return 0
+	}
+	return max(0, p.Current-p.Target)
+}
+
+// Skip — сколько ещё не обработанных сообщений будет пропущено.
+func (p PartitionSeek) Skip() int64 {
+	if p.Current < 0 {
+		return 0
+	}
+	return max(0, p.Target-p.Current)
+}
+
+// Seeker перематывает consumer group по топику: считает план и коммитит
+// его от имени группы. Коммит возможен только в пустую группу — пока
+// консьюмеры работают, они перезапишут позиции своими коммитами.
+type Seeker struct {
+	client *kafka.Client
+	group  string
+	topic  string
+}
+
+func NewSeeker(brokers []string, group, topic string) *Seeker {
+	return &Seeker{
+		client: &kafka.Client{Addr: kafka.TCP(brokers...), Timeout: 10 * time.Second},
+		group:  group,
+		topic:  topic,
+	}
+}
+
+// Plan считает новые позиции партиций (все, если partitions пуст). Оффсет
+// за границами партиции прижимается к ним; момент времени позже последнего
+// сообщения даёт конец партиции.
+func (s *Seeker) Plan(ctx context.Context, target SeekTarget, partitions []int) ([]PartitionSeek, error) {
+	ids, err := s.partitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(partitions) > 0 {
+		for _, p := range partitions {
+			if !slices.Contains(ids, p) {
+				return nil, fmt.Errorf("topic %s has no partition %d", s.topic, p)
+			}
+		}
+		ids = partitions
+	}
+
+	first, err := s.listOffsets(ctx, ids, kafka.FirstOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	last, err := s.listOffsets(ctx, ids, kafka.LastOffsetOf)
+	if err != nil {
+		return nil, err
+	}
+	var byTime map[int]kafka.PartitionOffsets
+	if target.kind == "time" {
+		byTime, err = s.listOffsets(ctx, ids, func(p int) kafka.OffsetRequest {
+			return kafka.TimeOffsetOf(p, target.at)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	current, err := s.committed(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make([]PartitionSeek, 0, len(ids))
+	for _, p := range ids {
+		ps := PartitionSeek{
+			Partition: p,
+			Current:   current[p],
+			Start:     first[p].FirstOffset,
+			End:       last[p].LastOffset,
+		}
+		switch target.kind {
+		case "earliest":
+			ps.Target = ps.Start
+		case "latest":
+			ps.Target = ps.End
+		case "offset":
+			ps.Target = min(max(target.offset, ps.Start), ps.End)
+		case "time":
+			ps.Target = ps.End
+			for off := range byTime[p].Offsets {
+				if off >= 0 {
+					ps.Target = off
+				}
+			}
+		}
+		plan = append(plan, ps)
+	}
+	return plan, nil
+}
+
+// ActiveMembers — сколько консьюмеров сейчас в группе.
+func (s *Seeker) ActiveMembers(ctx context.Context) (int, error) {
+	resp, err := s.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{s.group}})
+	if err != nil {
+		return 0, fmt.Errorf("describe group %s: %w", s.group, err)
+	}
+	for _, g := range resp.Groups {
+		if g.GroupID != s.group {
+			continue
+		}
+		if g.Error != nil {
+			return 0, fmt.Errorf("describe group %s: %w", s.group, g.Error)
+		}
+		return len(g.Members), nil
+	}
+	return 0, nil
+}
+
+// Commit коммитит позиции плана от имени группы.
+func (s *Seeker) Commit(ctx context.Context, plan []PartitionSeek) error {
+	if n, err := s.ActiveMembers(ctx); err != nil {
+		return err
+	} else if n > 0 {
+		return fmt.Errorf("group %s has %d active members, stop the consumers first", s.group, n)
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(plan))
+	for _, p := range plan {
+		commits = append(commits, kafka.OffsetCommit{Partition: p.Partition, Offset: p.Target})
+	}
+	resp, err := s.client.OffsetCommit(ctx, &kafka.OffsetCommitRequest{
+		GroupID:      s.group,
+		GenerationID: -1, // коммит вне поколения: группа пуста
+		Topics:       map[string][]kafka.OffsetCommit{s.topic: commits},
+	})
+	if err != nil {
+		return fmt.Errorf("commit offsets: %w", err)
+	}
+	var errs []error
+	for _, p := range resp.Topics[s.topic] {
+		if p.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", p.Partition, p.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Seeker) partitions(ctx context.Context) ([]int, error) {
+	resp, err := s.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{s.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("topic metadata: %w", err)
+	}
+	for _, t := range resp.Topics {
+		if t.Name != s.topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("topic %s: %w", s.topic, t.Error)
+		}
+		ids := make([]int, 0, len(t.Partitions))
+		for _, p := range t.Partitions {
+			ids = append(ids, p.ID)
+		}
+		slices.Sort(ids)
+		return ids, nil
+	}
+	return nil, fmt.Errorf("topic %s not found", s.topic)
+}
+
+// listOffsets — один запрос ListOffsets на партиции ids: Kafka не принимает
+// несколько запросов к одной партиции в одном сообщении.
+func (s *Seeker) listOffsets(ctx context.Context, ids []int, req func(int) kafka.OffsetRequest) (map[int]kafka.PartitionOffsets, error) {
+	reqs := make([]kafka.OffsetRequest, 0, len(ids))
+	for _, p := range ids {
+		reqs = append(reqs, req(p))
+	}
+	resp, err := s.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{s.topic: reqs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list offsets: %w", err)
+	}
+	out := make(map[int]kafka.PartitionOffsets, len(ids))
+	for _, p := range resp.Topics[s.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("list offsets of partition %d: %w", p.Partition, p.Error)
+		}
+		out[p.Partition] = p
+	}
+	return out, nil
+}
+
+// committed — текущие коммиты группы; -1 — коммита нет.
+func (s *Seeker) committed(ctx context.Context, ids []int) (map[int]int64, error) {
+	resp, err := s.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: s.group,
+		Topics:  map[string][]int{s.topic: ids},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetch offsets: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("fetch offsets: %w", resp.Error)
+	}
+	out := make(map[int]int64, len(ids))
+	for _, p := range ids {
+		out[p] = -1
+	}
+	for _, p := range resp.Topics[s.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("fetch offset of partition %d: %w", p.Partition, p.Error)
+		}
+		out[p.Partition] = p.CommittedOffset
+	}
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package dlq
+
+import (
+	"L0/internal/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// Stage — этап обработки, на котором сообщение было отвергнуто.
+type Stage string
+
+const (
+	StageDecode   Stage = "decode"   // не JSON / не раскладывается в model.Order
+	StageValidate Stage = "validate" // Order.Validate вернул ошибку
+	StageDB       Stage = "db"       // не удалось записать в PostgreSQL
+	StageStatus   Stage = "status"   // переход статуса запрещён или заказа так и не появилось
+)
+
+// Заголовки, которые добавляются к сообщению при публикации в DLQ.
+const (
+	headerPrefix = "x-dlq-"
+
+	HeaderStage     = headerPrefix + "stage"
+	HeaderError     = headerPrefix + "error"
+	HeaderTopic     = headerPrefix + "original-topic"
+	HeaderPartition = headerPrefix + "original-partition"
+	HeaderOffset    = headerPrefix + "original-offset"
+	HeaderAttempts  = headerPrefix + "attempts"
+	HeaderFailedAt  = headerPrefix + "failed-at"
+
+	// HeaderViolations — JSON-массив model.Violation, если заказ не прошёл проверку.
+	HeaderViolations = headerPrefix + "violations"
+)
+
+// Error — ошибка обработки с привязкой к этапу.
+type Error struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent — ошибки разбора и валидации повтором не исправить.
+func (e *Error) Permanent() bool {
+	return e.Stage == StageDecode || e.Stage == StageValidate
+}
+
+// WithStage помечает ошибку этапом обработки. nil остаётся nil.
+func WithStage(stage Stage, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Stage: stage, Err: err}
+}
+
+// StageOf достаёт этап из цепочки ошибок; непомеченные ошибки считаем ошибками БД.
+func StageOf(err error) Stage {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Stage
+	}
+	return StageDB
+}
+
+// Publisher перекладывает отвергнутые сообщения в dead-letter топик.
+type Publisher struct {
+	w     *kafka.Writer
+	topic string
+}
+
+func New(brokers []string, topic string) *Publisher {
+	return &Publisher{
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{}, // тот же ключ → та же партиция DLQ
+			RequiredAcks: kafka.RequireAll,
+		},
+		topic: topic,
+	}
+}
+
+// Publish синхронно пишет исходное сообщение (ключ, тело, заголовки) в DLQ
+// и дописывает заголовки с причиной отказа. Коммитить исходный оффсет можно
+// только после успешного возврата.
+func (p *Publisher) Publish(ctx context.Context, m kafka.Message, cause error, attempts int) error {
+	errText := ""
+	if cause != nil {
+		errText = cause.Error()
+	}
+
+	headers := make([]kafka.Header, 0, len(m.Headers)+8)
+	for _, h := range m.Headers {
+		// при повторном падении переобработанного сообщения старые пометки заменяем
+		if strings.HasPrefix(h.Key, headerPrefix) {
+			continue
+		}
+		headers = append(headers, h)
+	}
+	headers = append(headers,
+		kafka.Header{Key: HeaderStage, Value: []byte(StageOf(cause))},
+		kafka.Header{Key: HeaderError, Value: []byte(errText)},
+		kafka.Header{Key: HeaderTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderPartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+	var verr *model.ValidationError
+	if errors.As(cause, &verr) {
+		if data, err := json.Marshal(verr.Violations); err == nil {
+			headers = append(headers, kafka.Header{Key: HeaderViolations, Value: data})
+		}
+	}
+
+	if err := p.w.WriteMessages(ctx, kafka.Message{
+		Key:     m.Key,
+		Value:   m.Value,
+		Headers: headers,
+	}); err != nil {
+		return fmt.Errorf("dlq publish to %s: %w", p.topic, err)
+	}
+	return nil
+}
+
+func (p *Publisher) Topic() string {
+	return p.topic
+}
+
+func (p *Publisher) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,149 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Checker опрашивает зависимости сервиса и помнит результат последних
+// проверок: задержку, последнюю ошибку и когда она была.
+type Checker struct {
+	timeout time.Duration
+	checks  []*check
+}
+
+type check struct {
+	name string
+	fn   func(ctx context.Context) error
+
+	mu          sync.Mutex
+	lastErr     error
+	lastErrAt   time.Time
+	lastOKAt    time.Time
+	lastLatency time.Duration
+}
+
+// Result — состояние одной зависимости.
+type Result struct {
+	Name        string     `json:"name"`
+	Status      string     `json:"status"` // "ok" | "fail"
+	LatencyMs   float64    `json:"latency_ms"`
+	Error       string     `json:"error,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	LastOKAt    *time.Time `json:"last_ok_at,omitempty"`
+}
+
+// Report — сводка по всем зависимостям.
+type Report struct {
+	Status    string    `json:"status"` // "ok", если все проверки прошли
+	CheckedAt time.Time `json:"checked_at"`
+	Checks    []Result  `json:"checks"`
+}
+
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// New — timeout ограничивает каждую проверку.
+func New(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add регистрирует проверку; все они участвуют в готовности (/readyz).
+func (c *Checker) Add(name string, fn func(ctx context.Context) error) {
+	c.checks = append(c.checks, &check{name: name, fn: fn})
+}
+
+// Run выполняет все проверки параллельно.
+func (c *Checker) Run(ctx context.Context) Report {
+	rep := Report{
+		Status:    StatusOK,
+		CheckedAt: time.Now().UTC(),
+		Checks:    make([]Result, len(c.checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, ch := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rep.Checks[i] = ch.run(ctx, c.timeout)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range rep.Checks {
+		if r.Status != StatusOK {
+			rep.Status = StatusFail
+		}
+	}
+	return rep
+}
+
+func (ch *check) run(ctx context.Context, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := ch.fn(ctx)
+	latency := time.Since(start)
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	ch.lastLatency = latency
+	if err != nil {
+		ch.lastErr, ch.lastErrAt = err, start.UTC()
+	} else {
+		ch.lastOKAt = start.UTC()
+	}
+
+	res := Result{
+		Name:      ch.name,
+		Status:    StatusOK,
+		LatencyMs: float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	if ch.lastErr != nil {
+		t := ch.lastErrAt
+		res.LastError, res.LastErrorAt = ch.lastErr.Error(), &t
+	}
+	if !ch.lastOKAt.IsZero() {
+		t := ch.lastOKAt
+		res.LastOKAt = &t
+	}
+	return res
+}
+
+// Flag — проверка-переключатель для одноразовых событий вроде прогрева кеша.
+type Flag struct {
+	set atomic.Bool
+	err error
+}
+
+func NewFlag(notReady string) *Flag {
+	return &Flag{err: errors.New(notReady)}
+}
+
+func (f *Flag) Set() {
+	f.set.Store(true)
+}
+
+func (f *Flag) Check(context.Context) error {
+	if f.set.Load() {
+		return nil
+	}
+	return f.err
+}
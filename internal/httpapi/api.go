@@ -0,0 +1,212 @@
+package httpapi
+
+import (
+	"L0/internal/logging"
+	"L0/internal/model"
+	"L0/internal/repository"
+	"L0/internal/util"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// getOrder — GET /api/v1/orders/{id}; старый /order?id= ведёт сюда же.
+func (h *Handler) getOrder(w http.ResponseWriter, r *http.Request) {
+	defer util.Duration(util.Track("order"))
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "метод не поддерживается", nil)
+		return
+	}
+
+	orderId := r.PathValue("id")
+	if orderId == "" {
+		orderId = r.URL.Query().Get("id")
+	}
+	if orderId == "" {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "нет введённого id", nil)
+		return
+	}
+	logging.FromContext(r.Context()).Debug("запрос заказа", logging.KeyOrderUID, orderId)
+
+	order, _, err := h.repo.GetOrderById(r.Context(), orderId)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, order)
+}
+
+type itemStatusView struct {
+	RID    string           `json:"rid"`
+	ChrtID int64            `json:"chrt_id"`
+	Status string           `json:"status"`
+	Code   model.ItemStatus `json:"code"`
+}
+
+type statusView struct {
+	OrderUID string               `json:"order_uid"`
+	Status   model.Status         `json:"status"`
+	Next     []model.Status       `json:"next"` // куда заказ может перейти дальше
+	Items    []itemStatusView     `json:"items"`
+	History  []model.StatusChange `json:"history"`
+}
+
+// getOrderStatus — GET /api/v1/orders/{id}/status: текущий статус заказа
+// и позиций и история переходов.
+func (h *Handler) getOrderStatus(w http.ResponseWriter, r *http.Request) {
+	defer util.Duration(util.Track("order_status"))
+	id := r.PathValue("id")
+
+	order, _, err := h.repo.GetOrderById(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	history, err := h.repo.StatusHistory(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+
+	v := statusView{
+		OrderUID: order.OrderUID,
+		Status:   order.Status,
+		Next:     order.Status.Next(),
+		Items:    make([]itemStatusView, len(order.Items)),
+		History:  history,
+	}
+	for i, it := range order.Items {
+		v.Items[i] = itemStatusView{RID: it.RID, ChrtID: it.ChrtID, Status: it.Status.String(), Code: it.Status}
+	}
+	writeJSON(w, r, http.StatusOK, v)
+}
+
+// searchOrders — GET /api/v1/orders?customer_id=&track_number=&delivery_service=
+// &transaction=&from=&to=&nm_id=&brand=&limit=&cursor=
+// from/to — RFC 3339 или ГГГГ-ММ-ДД, to не включается.
+func (h *Handler) searchOrders(w http.ResponseWriter, r *http.Request) {
+	defer util.Duration(util.Track("orders"))
+	if r.Method != http.MethodGet {
+		writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "метод не поддерживается", nil)
+		return
+	}
+
+	f, err := parseOrderFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, err.Error(), nil)
+		return
+	}
+
+	page, err := h.repo.SearchOrders(r.Context(), f)
+	if err != nil {
+		writeRepoError(w, r, err)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, page)
+}
+
+func parseOrderFilter(q url.Values) (repository.OrderFilter, error) {
+	f := repository.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		Transaction:     q.Get("transaction"),
+		Brand:           q.Get("brand"),
+		Cursor:          q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.CreatedFrom, err = parseDate(v); err != nil {
+			return f, fmt.Errorf("некорректный from: %q", v)
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.CreatedTo, err = parseDate(v); err != nil {
+			return f, fmt.Errorf("некорректный to: %q", v)
+		}
+	}
+	if v := q.Get("nm_id"); v != "" {
+		if f.NmID, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return f, fmt.Errorf("некорректный nm_id: %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 || f.Limit > repository.MaxSearchLimit {
+			return f, fmt.Errorf("limit должен быть от 1 до %d", repository.MaxSearchLimit)
+		}
+	}
+	return f, nil
+}
+
+func parseDate(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// maxOrderBody — предел тела POST /api/v1/orders/validate.
+const maxOrderBody = 1 << 20
+
+type validationResult struct {
+	Valid    bool              `json:"valid"`
+	Warnings []model.Violation `json:"warnings,omitempty"`
+}
+
+// validateOrder — POST /api/v1/orders/validate: проверяет заказ теми же
+// правилами, что и консьюмер, и возвращает сразу все нарушения.
+// 200 — заказ корректен (в режиме warn — с warnings), 422 — список
+// нарушений в error.details.
+func (h *Handler) validateOrder(w http.ResponseWriter, r *http.Request) {
+	var o model.Order
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	if err := dec.Decode(&o); err != nil {
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "тело запроса — не JSON заказа: "+err.Error(), nil)
+		return
+	}
+
+	warnings, err := o.Check(h.rules)
+	if err != nil {
+		var verr *model.ValidationError
+		if errors.As(err, &verr) {
+			writeError(w, r, http.StatusUnprocessableEntity, codeValidationFailed,
+				fmt.Sprintf("заказ не прошёл проверку: нарушений %d", len(verr.Violations)), verr.Violations)
+			return
+		}
+		writeError(w, r, http.StatusUnprocessableEntity, codeValidationFailed, err.Error(), nil)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, validationResult{Valid: true, Warnings: warnings})
+}
+
+// apiMethods — методы, с которыми notFound проверяет, известен ли путь.
+var apiMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// notFound — JSON-ответ для путей под /api/, не подошедших ни к одному
+// маршруту. Шаблон "/api/" перехватывает и запросы к известному пути
+// с чужим методом, поэтому путь проверяется по mux с другими методами:
+// нашёлся — это 405 с Allow, как ответил бы сам mux.
+func notFound(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allow []string
+		for _, m := range apiMethods {
+			probe := r.Clone(r.Context())
+			probe.Method = m
+			if _, pattern := mux.Handler(probe); pattern != "" && pattern != "/api/" {
+				allow = append(allow, m)
+			}
+		}
+		if len(allow) > 0 {
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			writeError(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, "метод не поддерживается", nil)
+			return
+		}
+		writeError(w, r, http.StatusNotFound, codeNotFound, "нет такого метода API", nil)
+	}
+}
@@ -0,0 +1,76 @@
+package httpapi
+
+import (
+	"L0/internal/logging"
+	"L0/internal/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Коды ошибок API — стабильные, на них завязан фронтенд.
+const (
+	codeBadRequest       = "bad_request"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeTimeout          = "timeout"
+	codeValidationFailed = "validation_failed"
+	codeInternal         = "internal"
+)
+
+// errorBody — единый конверт ошибки: {"error": {...}}.
+type errorBody struct {
+	Error apiError `json:"error"`
+}
+
+type apiError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// writeJSON сначала сериализует ответ и только потом пишет статус,
+// чтобы при ошибке сериализации клиент получил 500, а не 200 с пустым телом.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("ошибка сериализации ответа", logging.Err(err))
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "внутренняя ошибка", nil)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string, details any) {
+	data, _ := json.Marshal(errorBody{Error: apiError{
+		Code:      code,
+		Message:   msg,
+		RequestID: requestID(r.Context()),
+		Details:   details,
+	}})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(append(data, '\n'))
+}
+
+// writeRepoError переводит ошибку репозитория в HTTP-статус: клиентские
+// ошибки отдаются как есть, внутренние логируются, а наружу уходит общий текст.
+func writeRepoError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		writeError(w, r, http.StatusNotFound, codeNotFound, "заказ не найден", nil)
+	case errors.Is(err, repository.ErrBadCursor):
+		writeError(w, r, http.StatusBadRequest, codeBadRequest, "некорректный cursor", nil)
+	case errors.Is(err, context.DeadlineExceeded):
+		logging.FromContext(r.Context()).Warn("таймаут запроса к БД", logging.Err(err))
+		writeError(w, r, http.StatusGatewayTimeout, codeTimeout, "превышено время ожидания", nil)
+	default:
+		logging.FromContext(r.Context()).Error("внутренняя ошибка", logging.Err(err))
+		writeError(w, r, http.StatusInternalServerError, codeInternal, "внутренняя ошибка", nil)
+	}
+}
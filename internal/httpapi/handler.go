@@ -1,20 +1,23 @@
 package httpapi
 
 import (
+	"L0/internal/health"
+	"L0/internal/logging"
+	"L0/internal/metrics"
+	"L0/internal/model"
 	"L0/internal/repository"
-	"L0/internal/util"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
-	"log"
 	"net/http"
 	"time"
-
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Handler struct {
-	repo repository.Repository
+	repo   *repository.Repository
+	health *health.Checker
+	rules  model.Rules // те же правила проверки, что у консьюмера
 }
 
 var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
@@ -26,6 +29,8 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
 	},
 	"money": func(v any) string {
 		switch x := v.(type) {
+		case model.Money:
+			return x.String() // "1817.00 RUB"
 		case int:
 			return fmt.Sprintf("%d", x)
 		case int64:
@@ -54,13 +59,14 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
   .kv{display:flex;justify-content:space-between;gap:12px}
   .muted{color:#6b7280}
   .pill{display:inline-block;padding:2px 8px;border-radius:999px;background:#eef2ff;color:#3730a3;font-size:12px}
+  .pill.status{background:#ecfdf5;color:#065f46}
   a{color:#2563eb;text-decoration:none}
 </style>
 </head>
 <body>
 
 <div class="card">
-  <h1>Заказ <span class="pill">{{.OrderUID}}</span></h1>
+  <h1>Заказ <span class="pill">{{.OrderUID}}</span> <span class="pill status">{{.Status}}</span></h1>
   <div class="muted">Создан: {{fmtTime .DateCreated}}</div>
 </div>
 
@@ -92,7 +98,7 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
     <div class="kv"><span>Провайдер</span><strong>{{.Payment.Provider}}</strong></div>
     <div class="kv"><span>Банк</span><strong>{{.Payment.Bank}}</strong></div>
     <div class="kv"><span>Доставка / Товары / Пошлина</span>
-      <strong>{{.Payment.DeliveryCost}} / {{.Payment.GoodsTotal}} / {{.Payment.CustomFee}}</strong>
+      <strong>{{money .Payment.DeliveryCost}} / {{money .Payment.GoodsTotal}} / {{money .Payment.CustomFee}}</strong>
     </div>
   </div>
 </div>
@@ -103,7 +109,7 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
     <thead>
       <tr>
         <th>RID</th><th>Название</th><th>Бренд</th><th>Цена</th>
-        <th>Скидка</th><th>Итого</th><th>Размер</th><th>Status</th>
+        <th>Скидка</th><th>Итого</th><th>Размер</th><th>Статус</th>
       </tr>
     </thead>
     <tbody>
@@ -112,15 +118,38 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
         <td>{{.RID}}</td>
         <td>{{.Name}}</td>
         <td>{{.Brand}}</td>
-        <td>{{.Price}}</td>
+        <td>{{money .Price}}</td>
         <td>{{.Sale}}</td>
-        <td>{{.TotalPrice}}</td>
+        <td>{{money .TotalPrice}}</td>
         <td>{{.Size}}</td>
-        <td>{{.Status}}</td>
+        <td>{{.Status}} <span class="muted">({{printf "%d" .Status}})</span></td>
+      </tr>
+    {{end}}
+    </tbody>
+  </table>
+</div>
+
+<div class="card">
+  <h2>История статусов</h2>
+  {{if .History}}
+  <table>
+    <thead>
+      <tr><th>Когда</th><th>Позиция</th><th>Переход</th><th>Причина</th></tr>
+    </thead>
+    <tbody>
+    {{range .History}}
+      <tr>
+        <td>{{fmtTime .ChangedAt}}</td>
+        <td>{{if .RID}}{{.RID}}{{else}}<span class="muted">заказ</span>{{end}}</td>
+        <td>{{if .From}}{{.From}} → {{end}}<strong>{{.To}}</strong></td>
+        <td>{{.Reason}}</td>
       </tr>
     {{end}}
     </tbody>
   </table>
+  {{else}}
+  <div class="muted">История не найдена</div>
+  {{end}}
 </div>
 
 <div class="muted">
@@ -131,31 +160,10 @@ var orderTmpl = template.Must(template.New("order").Funcs(template.FuncMap{
 </html>
 `))
 
-func (h *Handler) order(w http.ResponseWriter, r *http.Request) {
-	defer util.Duration(util.Track("order"))
-	orderId := r.URL.Query().Get("id")
-	if orderId == "" {
-		http.Error(w, "нет введённого id", http.StatusBadRequest)
-		return
-	}
-	log.Printf("Начинаю показывать заказ с id = %s\n", orderId)
-
-	order, ok, err := h.repo.GetOrderById(r.Context(), orderId)
-	if err != nil {
-		log.Printf("внутренняя ошибка при поиске заказа %s: %v", orderId, err)
-		http.Error(w, "внутренняя ошибка", http.StatusInternalServerError)
-		return
-	}
-	if !ok {
-		http.Error(w, "заказ не найден", http.StatusNotFound)
-		return
-	}
-
-	j, err := json.Marshal(order)
-	if err != nil {
-		log.Printf("bad order struct with id = %s error: %v", orderId, err)
-	}
-	w.Write(j)
+// orderPage — данные шаблона страницы заказа.
+type orderPage struct {
+	model.Order
+	History []model.StatusChange
 }
 
 func (h *Handler) form(w http.ResponseWriter, r *http.Request) {
@@ -175,19 +183,27 @@ func (h *Handler) form(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		o, ok, err := h.repo.GetOrderById(r.Context(), id)
+		o, _, err := h.repo.GetOrderById(r.Context(), id)
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "заказ не найден", http.StatusNotFound)
+			return
+		}
 		if err != nil {
-			log.Printf("внутренняя ошибка при поиске заказа %s: %v", id, err)
+			logging.FromContext(r.Context()).Error("внутренняя ошибка при поиске заказа",
+				logging.KeyOrderUID, id, logging.Err(err))
 			http.Error(w, "внутренняя ошибка", http.StatusInternalServerError)
 			return
 		}
-		if !ok {
-			http.Error(w, "заказ не найден", http.StatusNotFound)
-			return
+
+		history, err := h.repo.StatusHistory(r.Context(), id)
+		if err != nil {
+			// без истории страница заказа всё равно полезна
+			logging.FromContext(r.Context()).Warn("не удалось прочитать историю статусов",
+				logging.KeyOrderUID, id, logging.Err(err))
 		}
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		if err := orderTmpl.Execute(w, o); err != nil {
+		if err := orderTmpl.Execute(w, orderPage{Order: o, History: history}); err != nil {
 			http.Error(w, "template error", http.StatusInternalServerError)
 			return
 		}
@@ -197,17 +213,62 @@ func (h *Handler) form(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func Run(pool *pgxpool.Pool, addr string) {
+// Server — HTTP-сервер сервиса с мягкой остановкой.
+type Server struct {
+	srv *http.Server
+}
+
+func NewServer(repo *repository.Repository, checker *health.Checker, rules model.Rules, addr string) *Server {
 	some := Handler{
-		repo: repository.New(pool),
+		repo:   repo,
+		health: checker,
+		rules:  rules,
 	}
 
-	http.HandleFunc("/order", some.order)
-	http.HandleFunc("/form", some.form)
+	mux := http.NewServeMux()
+
+	// REST API v1
+	mux.HandleFunc("GET /api/v1/orders/{id}", some.getOrder)
+	mux.HandleFunc("GET /api/v1/orders/{id}/status", some.getOrderStatus)
+	mux.HandleFunc("GET /api/v1/orders", some.searchOrders)
+	mux.HandleFunc("POST /api/v1/orders/validate", some.validateOrder)
+	mux.HandleFunc("/api/", notFound(mux))
+
+	// старые адреса оставлены для совместимости
+	mux.HandleFunc("/order", some.getOrder)
+	mux.HandleFunc("/orders", some.searchOrders)
+
+	// HTML-интерфейс
+	mux.HandleFunc("/form", some.form)
+
+	// проверки для оркестратора
+	mux.HandleFunc("GET /healthz", some.healthz)
+	mux.HandleFunc("GET /readyz", some.readyz)
+	mux.HandleFunc("GET /health/details", some.healthDetails)
+	mux.Handle("GET /metrics", metrics.Handler())
 
-	log.Printf("начинаю слушать localhost:%s", addr)
+	return &Server{srv: &http.Server{
+		Addr:              addr,
+		Handler:           withRequestID(withInstrumentation(mux)),
+		ReadHeaderTimeout: 5 * time.Second,
+	}}
+}
+
+// Run слушает addr до Stop; ErrServerClosed — штатная остановка.
+func (s *Server) Run(ctx context.Context) error {
+	logging.FromContext(ctx).Info("HTTP-сервер слушает", "addr", s.srv.Addr)
+	if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
 
-	if err := http.ListenAndServe(addr, nil); err != nil && err != http.ErrServerClosed {
-		log.Printf("http server error: %v", err)
+// Stop перестаёт принимать соединения и ждёт завершения текущих запросов;
+// по истечении ctx оставшиеся соединения закрываются.
+func (s *Server) Stop(ctx context.Context) error {
+	if err := s.srv.Shutdown(ctx); err != nil {
+		s.srv.Close()
+		return err
 	}
+	return nil
 }
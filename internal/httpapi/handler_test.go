@@ -0,0 +1,57 @@
+package httpapi
+
+import (
+	"L0/internal/health"
+	"L0/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUnknownAPIRoutes(t *testing.T) {
+	srv := NewServer(nil, health.New(time.Second), model.Rules{}, ":0")
+
+	tests := []struct {
+		method, path string
+		status       int
+		allow        string
+	}{
+		{http.MethodPost, "/api/v1/orders", http.StatusMethodNotAllowed, "GET"},
+		{http.MethodDelete, "/api/v1/orders/abc", http.StatusMethodNotAllowed, "GET"},
+		{http.MethodPut, "/api/v1/orders/abc/status", http.StatusMethodNotAllowed, "GET"},
+		{http.MethodGet, "/api/v1/orders/abc/items", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound, ""},
+		{http.MethodPost, "/api/v2/orders", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			srv.srv.Handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d; body %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want JSON", ct)
+			}
+		})
+	}
+}
+
+func TestMethodLabel(t *testing.T) {
+	for method, want := range map[string]string{
+		http.MethodGet:     http.MethodGet,
+		http.MethodOptions: http.MethodOptions,
+		"get":              "other",
+		"BREW":             "other",
+		http.MethodTrace:   "other",
+	} {
+		if got := methodLabel(method); got != want {
+			t.Errorf("methodLabel(%q) = %q, want %q", method, got, want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package httpapi
+
+import (
+	"L0/internal/health"
+	"net/http"
+)
+
+// healthz — процесс жив и обслуживает HTTP; зависимости не проверяются.
+func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// readyz — можно ли слать сюда трафик: БД отвечает, ридер в группе, кеш прогрет.
+func (h *Handler) readyz(w http.ResponseWriter, r *http.Request) {
+	rep := h.health.Run(r.Context())
+	status := http.StatusOK
+	if !rep.OK() {
+		status = http.StatusServiceUnavailable
+	}
+
+	failed := []string{}
+	for _, c := range rep.Checks {
+		if c.Status != health.StatusOK {
+			failed = append(failed, c.Name)
+		}
+	}
+	writeJSON(w, r, status, map[string]any{"status": rep.Status, "failed": failed})
+}
+
+// healthDetails — подробный отчёт: задержка и последняя ошибка по каждой зависимости.
+func (h *Handler) healthDetails(w http.ResponseWriter, r *http.Request) {
+	rep := h.health.Run(r.Context())
+	status := http.StatusOK
+	if !rep.OK() {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, r, status, rep)
+}
@@ -0,0 +1,184 @@
+package lifecycle
+
+import (
+	"L0/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// abortGrace — сколько ещё ждём компоненты после того, как дедлайн дренажа
+// истёк и их контекст отменён принудительно.
+const abortGrace = 5 * time.Second
+
+// Component — часть сервиса с собственным циклом работы.
+type Component struct {
+	Name string
+
+	// Run блокируется до остановки. Возврат до начала остановки считается
+	// сбоем (если есть ошибка) и запускает остановку всего сервиса.
+	// Отмена ctx — принудительная остановка: бросить работу как есть.
+	Run func(ctx context.Context) error
+
+	// Stop просит компонент мягко завершиться (дочитать/дописать своё) и не
+	// ждёт. nil — компонент завершается сам, когда иссякает его вход.
+	Stop func(ctx context.Context) error
+}
+
+type running struct {
+	Component
+	done     chan struct{}
+	err      error
+	reported bool // ошибка уже учтена как причина остановки
+}
+
+// Manager запускает компоненты в порядке добавления и останавливает их
+// в обратном: поэтому добавлять нужно от зависимостей к зависящим
+// (HTTP → коммиттер → воркеры → чтение из Kafka).
+type Manager struct {
+	drain      time.Duration
+	components []Component
+}
+
+// New — drain ограничивает всю мягкую остановку; по его истечении
+// оставшиеся компоненты останавливаются принудительно.
+func New(drain time.Duration) *Manager {
+	return &Manager{drain: drain}
+}
+
+func (m *Manager) Add(c Component) {
+	m.components = append(m.components, c)
+}
+
+// Run запускает все компоненты и ждёт отмены ctx (сигнал) или сбоя любого
+// из них, после чего останавливает всё по порядку. Возвращает ошибку, если
+// какой-то компонент упал или не уложился в дедлайн дренажа.
+func (m *Manager) Run(ctx context.Context) error {
+	runCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
+
+	lg := logging.FromContext(ctx)
+	exited := make(chan *running, len(m.components))
+	rs := make([]*running, 0, len(m.components))
+	for _, c := range m.components {
+		r := &running{Component: c, done: make(chan struct{})}
+		rs = append(rs, r)
+		// логгер компонента получает поле component
+		compCtx := logging.With(runCtx, logging.KeyComponent, c.Name)
+		go func() {
+			defer close(r.done)
+			r.err = r.Run(compCtx)
+			exited <- r
+		}()
+		lg.Info("компонент запущен", logging.KeyComponent, c.Name)
+	}
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		lg.Info("получен сигнал остановки")
+	case r := <-exited:
+		r.reported = true
+		if r.err != nil {
+			lg.Error("компонент упал", logging.KeyComponent, r.Name, logging.Err(r.err))
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, r.err))
+		} else {
+			lg.Error("компонент неожиданно завершился", logging.KeyComponent, r.Name)
+			errs = append(errs, fmt.Errorf("%s: exited unexpectedly", r.Name))
+		}
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), m.drain)
+	defer cancel()
+
+	aborted := false
+	for i := len(rs) - 1; i >= 0; i-- {
+		r := rs[i]
+		lg.Info("останавливаю компонент", logging.KeyComponent, r.Name)
+		if r.Stop != nil {
+			if err := r.Stop(drainCtx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: stop: %w", r.Name, err))
+			}
+		}
+
+		if !aborted {
+			select {
+			case <-r.done:
+			case <-drainCtx.Done():
+				// дедлайн истёк: этот и оставшиеся компоненты добиваем отменой контекста
+				lg.Warn("компонент не уложился в дедлайн, останавливаю принудительно",
+					logging.KeyComponent, r.Name, "drain", m.drain)
+				errs = append(errs, fmt.Errorf("%s: drain deadline exceeded", r.Name))
+				abort()
+				aborted = true
+			}
+		}
+		if aborted {
+			select {
+			case <-r.done:
+			case <-time.After(abortGrace):
+				errs = append(errs, fmt.Errorf("%s: did not stop after abort", r.Name))
+				continue
+			}
+		}
+		if r.err != nil && !r.reported && !errors.Is(r.err, context.Canceled) {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name, r.err))
+		}
+		lg.Info("компонент остановлен", logging.KeyComponent, r.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Task — компонент, который один раз выполняет fn (например, прогрев кеша)
+// и дальше просто ждёт остановки. Ошибка fn считается сбоем компонента.
+func Task(name string, fn func(ctx context.Context) error) Component {
+	stop := make(chan struct{})
+	var once sync.Once
+	return Component{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			if err := fn(ctx); err != nil {
+				return err
+			}
+			select {
+			case <-stop:
+			case <-ctx.Done():
+			}
+			return nil
+		},
+		Stop: func(context.Context) error {
+			once.Do(func() { close(stop) })
+			return nil
+		},
+	}
+}
+
+// After — компонент c, который начинает работу только после закрытия ready
+// (например, по окончании прогрева кеша). Stop, пришедший раньше, тоже
+// запускает c: тот сразу увидит остановку и выйдет своим обычным путём,
+// закрыв то, что должен закрыть.
+func After(ready <-chan struct{}, c Component) Component {
+	stop := make(chan struct{})
+	var once sync.Once
+	return Component{
+		Name: c.Name,
+		Run: func(ctx context.Context) error {
+			select {
+			case <-ready:
+			case <-stop:
+			case <-ctx.Done():
+			}
+			return c.Run(ctx)
+		},
+		Stop: func(ctx context.Context) error {
+			once.Do(func() { close(stop) })
+			if c.Stop == nil {
+				return nil
+			}
+			return c.Stop(ctx)
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Ключи полей, общие для всех пакетов: по ним сообщения одного заказа,
+// запроса или воркера собираются в агрегаторе логов.
+const (
+	KeyOrderUID  = "order_uid"
+	KeyPartition = "partition"
+	KeyOffset    = "offset"
+	KeyWorkerID  = "worker_id"
+	KeyRequestID = "request_id"
+	KeyStage     = "stage"
+	KeyComponent = "component"
+	KeyTraceID   = "trace_id"
+	KeyError     = "error"
+)
+
+// New собирает логгер по настройкам из config: level — debug|info|warn|error,
+// format — text|json.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want text or json)", format)
+	}
+}
+
+type ctxKey struct{}
+
+// WithLogger кладёт логгер в контекст.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext достаёт логгер из контекста; если его там нет — slog.Default().
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// With добавляет поля к логгеру из контекста и возвращает новый контекст.
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
+
+// Err — поле с ошибкой.
+func Err(err error) slog.Attr {
+	return slog.Any(KeyError, err)
+}
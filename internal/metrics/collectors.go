@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"L0/internal/cache"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector снимает pgxpool.Stat() при каждом scrape.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquired, idle, constructing, total, max *prometheus.Desc
+	acquires, emptyAcquires, canceled        *prometheus.Desc
+	acquireSeconds                           *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+	return &poolCollector{
+		pool:           pool,
+		acquired:       desc("acquired_conns", "Соединения, занятые запросами."),
+		idle:           desc("idle_conns", "Свободные соединения."),
+		constructing:   desc("constructing_conns", "Соединения, которые сейчас открываются."),
+		total:          desc("total_conns", "Все соединения пула."),
+		max:            desc("max_conns", "Лимит соединений пула."),
+		acquires:       desc("acquires_total", "Успешные захваты соединения."),
+		emptyAcquires:  desc("empty_acquires_total", "Захваты, которым пришлось ждать свободное соединение."),
+		canceled:       desc("canceled_acquires_total", "Захваты, отменённые контекстом."),
+		acquireSeconds: desc("acquire_seconds_total", "Суммарное время ожидания соединений."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		c.acquired, c.idle, c.constructing, c.total, c.max,
+		c.acquires, c.emptyAcquires, c.canceled, c.acquireSeconds,
+	} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.pool.Stat()
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+	gauge(c.acquired, float64(s.AcquiredConns()))
+	gauge(c.idle, float64(s.IdleConns()))
+	gauge(c.constructing, float64(s.ConstructingConns()))
+	gauge(c.total, float64(s.TotalConns()))
+	gauge(c.max, float64(s.MaxConns()))
+	counter(c.acquires, float64(s.AcquireCount()))
+	counter(c.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(c.canceled, float64(s.CanceledAcquireCount()))
+	counter(c.acquireSeconds, s.AcquireDuration().Seconds())
+}
+
+// cacheCollector снимает cache.Stats() при каждом scrape; name попадает
+// в метку cache, чтобы кеш заказов и отрицательный кеш шли одной метрикой.
+type cacheCollector struct {
+	stats func() cache.Stats
+
+	entries, bytes                       *prometheus.Desc
+	hits, misses, evictions, expirations *prometheus.Desc
+}
+
+func NewCacheCollector(name string, stats func() cache.Stats) prometheus.Collector {
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", metric), help,
+			nil, prometheus.Labels{"cache": name})
+	}
+	return &cacheCollector{
+		stats:       stats,
+		entries:     desc("entries", "Записи в кеше."),
+		bytes:       desc("bytes", "Приблизительный объём кеша в байтах."),
+		hits:        desc("hits_total", "Попадания в кеш."),
+		misses:      desc("misses_total", "Промахи кеша."),
+		evictions:   desc("evictions_total", "Записи, вытесненные по лимиту."),
+		expirations: desc("expirations_total", "Записи, удалённые по TTL."),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.entries, c.bytes, c.hits, c.misses, c.evictions, c.expirations} {
+		ch <- d
+	}
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(s.Entries))
+	ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(s.Bytes))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(s.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(s.Expirations))
+}
@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "l0"
+
+// Чтение из Kafka и запись в БД.
+var (
+	MessagesFetched = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "messages_fetched_total",
+		Help: "Сообщения, прочитанные из Kafka.",
+	})
+	MessagesCommitted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "messages_committed_total",
+		Help: "Сообщения, чьи оффсеты закоммичены.",
+	})
+	MessagesFailed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "messages_failed_total",
+		Help: "Сообщения, окончательно не записанные в БД, по этапу (decode, validate, db, status).",
+	}, []string{"stage"})
+	MessagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "messages_dead_lettered_total",
+		Help: "Сообщения, отправленные в DLQ, по этапу.",
+	}, []string{"stage"})
+	DeadLetterErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "dead_letter_errors_total",
+		Help: "Неудачные попытки публикации в DLQ; полоса воркера ждёт, пока DLQ не примет сообщение.",
+	})
+	FinanceWarnings = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "finance_warnings_total",
+		Help: "Заказы, принятые с несходящимися суммами (FINANCE_CHECK_MODE=warn).",
+	})
+	Retries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "retries_total",
+		Help: "Повторные попытки записи после временных ошибок.",
+	})
+	ProcessDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "process_duration_seconds",
+		Help:    "Длительность одной попытки parsJsonToDB: разбор, проверка и запись.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"}) // "ok" | "error"
+	Lag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "lag",
+		Help: "Отставание от конца партиции на момент последнего чтения, сообщений.",
+	}, []string{"partition"})
+	ProduceToStored = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "produce_to_stored_seconds",
+		Help:    "От отправки продюсером (kafka.Message.Time) до записи заказа в БД.",
+		Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	})
+	BatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "batch_size",
+		Help:    "Заказов в одной пачке записи (BATCH_SIZE > 1).",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500},
+	})
+	BatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "batch_duration_seconds",
+		Help:    "Длительность записи пачки одной транзакцией.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"result"}) // "ok" | "error"
+	BatchFallbacks = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "batch_fallbacks_total",
+		Help: "Пачки, которые не удалось записать целиком и пришлось писать по одному заказу.",
+	})
+	Uncommitted = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "uncommitted",
+		Help: "Выданные воркерам сообщения, оффсеты которых ещё не закоммичены.",
+	}, []string{"partition"})
+	QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "consumer", Name: "queue_depth",
+		Help: "Прочитанные, но ещё не взятые воркерами сообщения.",
+	})
+)
+
+// Outbox: события о заказах для других сервисов.
+var (
+	OutboxPublished = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbox", Name: "published_total",
+		Help: "События outbox, опубликованные в Kafka.",
+	})
+	OutboxPublishErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbox", Name: "publish_errors_total",
+		Help: "Неудачные публикации событий outbox (событие будет отправлено повторно).",
+	})
+	OutboxParked = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbox", Name: "parked_total",
+		Help: "События outbox, отложенные после OUTBOX_MAX_ATTEMPTS отказов брокера (failed_at).",
+	})
+	OutboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace, Subsystem: "outbox", Name: "pending",
+		Help: "События outbox, ещё не опубликованные в Kafka.",
+	})
+)
+
+// HTTP.
+var (
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "Длительность HTTP-запросов по шаблону маршрута, методу и статусу.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// OperationDuration наполняет util.Duration.
+	OperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Name: "operation_duration_seconds",
+		Help:    "Длительность операций, замеренных через util.Track/util.Duration.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"operation"})
+)
+
+// Register добавляет коллекторы (пул БД, кеши) в общий реестр.
+func Register(cs ...prometheus.Collector) {
+	prometheus.MustRegister(cs...)
+}
+
+// Handler отдаёт метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS public.order_items;
+DROP TABLE IF EXISTS public.payments;
+DROP TABLE IF EXISTS public.deliveries;
+DROP TABLE IF EXISTS public.orders;
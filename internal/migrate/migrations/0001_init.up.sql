@@ -0,0 +1,68 @@
+-- Исходная схема. IF NOT EXISTS — чтобы базы, созданные раньше вручную
+-- по README, принимали миграции без ошибок.
+
+-- ====== ORDERS ======
+CREATE TABLE IF NOT EXISTS public.orders (
+  order_uid          TEXT PRIMARY KEY,
+  track_number       TEXT        NOT NULL,
+  entry              TEXT        NOT NULL,
+  locale             TEXT,
+  internal_signature TEXT,
+  customer_id        TEXT        NOT NULL,
+  delivery_service   TEXT,
+  shardkey           TEXT,
+  sm_id              INT,
+  date_created       TIMESTAMPTZ NOT NULL,
+  oof_shard          TEXT
+);
+
+-- ====== DELIVERIES ======
+-- В JSON это тоже order_uid, в БД храним как order_id (FK на orders.order_uid)
+CREATE TABLE IF NOT EXISTS public.deliveries (
+  order_id TEXT PRIMARY KEY
+           REFERENCES public.orders(order_uid) ON DELETE CASCADE,
+  name     TEXT,
+  phone    TEXT,
+  zip      TEXT,
+  city     TEXT,
+  address  TEXT,
+  region   TEXT,
+  email    TEXT
+);
+
+-- ====== PAYMENTS ======
+CREATE TABLE IF NOT EXISTS public.payments (
+  order_id      TEXT PRIMARY KEY
+                REFERENCES public.orders(order_uid) ON DELETE CASCADE,
+  transaction   TEXT    UNIQUE NOT NULL,
+  request_id    TEXT,
+  currency      TEXT    NOT NULL,
+  provider      TEXT,
+  amount        DOUBLE PRECISION NOT NULL,  -- float64
+  payment_dt    BIGINT NOT NULL,            -- int64 (unix)
+  bank          TEXT,
+  delivery_cost INT,
+  goods_total   INT,
+  custom_fee    INT
+);
+
+-- ====== ORDER ITEMS ======
+CREATE TABLE IF NOT EXISTS public.order_items (
+  id           BIGSERIAL PRIMARY KEY,
+  order_id     TEXT REFERENCES public.orders(order_uid) ON DELETE CASCADE,
+  chrt_id      BIGINT,       -- int64
+  track_number TEXT,
+  price        INT,
+  rid          TEXT,
+  name         TEXT,
+  sale         INT,
+  size         TEXT,
+  total_price  INT,
+  nm_id        BIGINT,       -- int64
+  brand        TEXT,
+  status       INT
+);
+
+-- Полезные индексы
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON public.order_items(order_id);
+CREATE INDEX IF NOT EXISTS idx_payments_transaction  ON public.payments(transaction);
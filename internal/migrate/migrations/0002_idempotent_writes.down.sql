@@ -0,0 +1,2 @@
+ALTER TABLE public.order_items DROP CONSTRAINT IF EXISTS order_items_order_id_chrt_id_rid_key;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS payload_hash;
@@ -0,0 +1,26 @@
+-- Идемпотентная запись: отпечаток содержимого заказа и уникальность
+-- позиции внутри заказа, на которую опирается ON CONFLICT в writer.go.
+
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS payload_hash TEXT; -- sha256 канонического JSON
+
+DO $$
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM pg_constraint
+    WHERE conrelid = 'public.order_items'::regclass
+      AND conname = 'order_items_order_id_chrt_id_rid_key'
+  ) THEN
+    -- повторные доставки до этой миграции могли задвоить позиции:
+    -- оставляем самую раннюю запись каждой
+    DELETE FROM public.order_items a
+    USING public.order_items b
+    WHERE a.order_id = b.order_id
+      AND a.chrt_id IS NOT DISTINCT FROM b.chrt_id
+      AND a.rid IS NOT DISTINCT FROM b.rid
+      AND a.id > b.id;
+
+    ALTER TABLE public.order_items
+      ADD CONSTRAINT order_items_order_id_chrt_id_rid_key UNIQUE (order_id, chrt_id, rid);
+  END IF;
+END
+$$;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS public.idx_order_items_brand;
+DROP INDEX IF EXISTS public.idx_order_items_nm_id;
+DROP INDEX IF EXISTS public.idx_orders_delivery_service;
+DROP INDEX IF EXISTS public.idx_orders_track_number;
+DROP INDEX IF EXISTS public.idx_orders_customer_id;
+DROP INDEX IF EXISTS public.idx_orders_created;
@@ -0,0 +1,7 @@
+-- Индексы для поиска GET /api/v1/orders и прогрева кеша
+CREATE INDEX IF NOT EXISTS idx_orders_created          ON public.orders(date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id      ON public.orders(customer_id);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number     ON public.orders(track_number);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON public.orders(delivery_service);
+CREATE INDEX IF NOT EXISTS idx_order_items_nm_id       ON public.order_items(nm_id);
+CREATE INDEX IF NOT EXISTS idx_order_items_brand       ON public.order_items(brand);
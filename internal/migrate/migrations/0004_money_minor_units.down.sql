@@ -0,0 +1,49 @@
+-- Обратно к единицам валюты. INT-колонки теряют дробную часть (копейки
+-- округляются) — так они и хранились до 0004.
+
+CREATE OR REPLACE FUNCTION pg_temp.minor_digits(cur TEXT) RETURNS INT
+LANGUAGE sql IMMUTABLE AS $$
+  SELECT CASE
+    WHEN cur IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG',
+                 'RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF') THEN 0
+    WHEN cur IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND') THEN 3
+    WHEN cur IN ('CLF','UYW') THEN 4
+    ELSE 2
+  END
+$$;
+
+-- ====== ORDER ITEMS ======
+ALTER TABLE public.order_items
+  ADD COLUMN price       INT,
+  ADD COLUMN total_price INT;
+
+UPDATE public.order_items i SET
+  price       = round(i.price_minor       / power(10::numeric, pg_temp.minor_digits(p.currency))),
+  total_price = round(i.total_price_minor / power(10::numeric, pg_temp.minor_digits(p.currency)))
+FROM public.payments p
+WHERE p.order_id = i.order_id;
+
+UPDATE public.order_items SET
+  price       = round(price_minor / 100.0),
+  total_price = round(total_price_minor / 100.0)
+WHERE price IS NULL;
+
+ALTER TABLE public.order_items
+  DROP COLUMN price_minor,
+  DROP COLUMN total_price_minor;
+
+-- ====== PAYMENTS ======
+ALTER TABLE public.payments RENAME COLUMN amount_minor        TO amount;
+ALTER TABLE public.payments RENAME COLUMN delivery_cost_minor TO delivery_cost;
+ALTER TABLE public.payments RENAME COLUMN goods_total_minor   TO goods_total;
+ALTER TABLE public.payments RENAME COLUMN custom_fee_minor    TO custom_fee;
+
+ALTER TABLE public.payments
+  ALTER COLUMN amount        TYPE DOUBLE PRECISION
+    USING (amount / power(10::numeric, pg_temp.minor_digits(currency)))::double precision,
+  ALTER COLUMN delivery_cost TYPE INT
+    USING round(delivery_cost / power(10::numeric, pg_temp.minor_digits(currency))),
+  ALTER COLUMN goods_total   TYPE INT
+    USING round(goods_total / power(10::numeric, pg_temp.minor_digits(currency))),
+  ALTER COLUMN custom_fee    TYPE INT
+    USING round(custom_fee / power(10::numeric, pg_temp.minor_digits(currency)));
@@ -0,0 +1,53 @@
+-- Деньги — BIGINT в минимальных единицах валюты (копейки, центы) вместо
+-- DOUBLE PRECISION и INT в единицах валюты. Колонки переименованы в *_minor:
+-- бинарник до этой миграции упадёт на записи, а не запишет рубли как копейки.
+
+-- Знаков после запятой по ISO 4217 — снимок таблицы refdata.MinorUnits.
+CREATE OR REPLACE FUNCTION pg_temp.minor_digits(cur TEXT) RETURNS INT
+LANGUAGE sql IMMUTABLE AS $$
+  SELECT CASE
+    WHEN cur IN ('BIF','CLP','DJF','GNF','ISK','JPY','KMF','KRW','PYG',
+                 'RWF','UGX','UYI','VND','VUV','XAF','XOF','XPF') THEN 0
+    WHEN cur IN ('BHD','IQD','JOD','KWD','LYD','OMR','TND') THEN 3
+    WHEN cur IN ('CLF','UYW') THEN 4
+    ELSE 2
+  END
+$$;
+
+-- ====== ORDER ITEMS ======
+-- валюта позиции — валюта оплаты заказа, поэтому пересчёт через JOIN
+ALTER TABLE public.order_items
+  ADD COLUMN price_minor       BIGINT,
+  ADD COLUMN total_price_minor BIGINT;
+
+UPDATE public.order_items i SET
+  price_minor       = i.price       * power(10::numeric, pg_temp.minor_digits(p.currency)),
+  total_price_minor = i.total_price * power(10::numeric, pg_temp.minor_digits(p.currency))
+FROM public.payments p
+WHERE p.order_id = i.order_id;
+
+-- позиции без оплаты (не должно быть, но схема не запрещает) — как у RUB
+UPDATE public.order_items SET
+  price_minor       = price * 100,
+  total_price_minor = total_price * 100
+WHERE price_minor IS NULL;
+
+ALTER TABLE public.order_items
+  DROP COLUMN price,
+  DROP COLUMN total_price;
+
+-- ====== PAYMENTS ======
+ALTER TABLE public.payments
+  ALTER COLUMN amount        TYPE BIGINT
+    USING round(amount::numeric * power(10::numeric, pg_temp.minor_digits(currency))),
+  ALTER COLUMN delivery_cost TYPE BIGINT
+    USING delivery_cost * power(10::numeric, pg_temp.minor_digits(currency)),
+  ALTER COLUMN goods_total   TYPE BIGINT
+    USING goods_total * power(10::numeric, pg_temp.minor_digits(currency)),
+  ALTER COLUMN custom_fee    TYPE BIGINT
+    USING custom_fee * power(10::numeric, pg_temp.minor_digits(currency));
+
+ALTER TABLE public.payments RENAME COLUMN amount        TO amount_minor;
+ALTER TABLE public.payments RENAME COLUMN delivery_cost TO delivery_cost_minor;
+ALTER TABLE public.payments RENAME COLUMN goods_total   TO goods_total_minor;
+ALTER TABLE public.payments RENAME COLUMN custom_fee    TO custom_fee_minor;
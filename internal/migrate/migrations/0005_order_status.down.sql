@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.order_status_history;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS status;
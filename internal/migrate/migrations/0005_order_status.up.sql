@@ -0,0 +1,24 @@
+-- Статусы заказа: текущий — в orders.status, все переходы заказа и позиций —
+-- в order_status_history. Позиции хранят статус числовым кодом в
+-- order_items.status (202 — created, см. model.ItemStatus).
+
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'created';
+
+CREATE TABLE IF NOT EXISTS public.order_status_history (
+  id          BIGSERIAL PRIMARY KEY,
+  order_id    TEXT        NOT NULL REFERENCES public.orders(order_uid) ON DELETE CASCADE,
+  rid         TEXT,                 -- NULL — статус заказа, иначе позиции
+  from_status TEXT,                 -- NULL — первая запись (заказ принят)
+  to_status   TEXT        NOT NULL,
+  reason      TEXT,
+  changed_at  TIMESTAMPTZ NOT NULL, -- время смены у источника события
+  recorded_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON public.order_status_history(order_id, id);
+
+-- у заказов, записанных раньше, история начинается с приёма
+INSERT INTO public.order_status_history (order_id, to_status, changed_at)
+SELECT o.order_uid, o.status, o.date_created
+FROM public.orders o
+WHERE NOT EXISTS (SELECT 1 FROM public.order_status_history h WHERE h.order_id = o.order_uid);
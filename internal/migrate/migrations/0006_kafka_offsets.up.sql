@@ -0,0 +1,12 @@
+-- Позиции чтения Kafka для режима KAFKA_OFFSET_STORE=postgres: оффсет
+-- сообщения пишется той же транзакцией, что и его данные, и при назначении
+-- партиции чтение продолжается отсюда, а не с коммита в Kafka.
+
+CREATE TABLE IF NOT EXISTS public.kafka_offsets (
+  group_id    TEXT        NOT NULL,
+  topic       TEXT        NOT NULL,
+  partition   INT         NOT NULL,
+  next_offset BIGINT      NOT NULL, -- первый ещё не применённый оффсет
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (group_id, topic, partition)
+);
@@ -0,0 +1,18 @@
+-- Outbox: события о записанных заказах пишутся той же транзакцией, что и
+-- заказ, и публикуются в Kafka ретранслятором (internal/outbox). Опубликованные
+-- строки удаляются по истечении OUTBOX_RETENTION.
+
+CREATE TABLE IF NOT EXISTS public.outbox (
+  id           BIGSERIAL PRIMARY KEY,
+  event_type   TEXT        NOT NULL,              -- order.stored | order.updated
+  aggregate_id TEXT        NOT NULL,              -- order_uid, ключ сообщения
+  payload      JSONB       NOT NULL,
+  headers      JSONB       NOT NULL DEFAULT '{}', -- контекст трассировки (traceparent)
+  created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+  attempts     INT         NOT NULL DEFAULT 0,    -- неудачные публикации
+  last_error   TEXT,
+  published_at TIMESTAMPTZ                        -- NULL — ещё не опубликовано
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending   ON public.outbox(id)           WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON public.outbox(published_at) WHERE published_at IS NOT NULL;
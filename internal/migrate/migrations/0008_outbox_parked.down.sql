@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON public.outbox(id) WHERE published_at IS NULL;
+
+ALTER TABLE public.outbox DROP COLUMN IF EXISTS failed_at;
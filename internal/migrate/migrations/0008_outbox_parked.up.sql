@@ -0,0 +1,9 @@
+-- Событие, которое брокер отверг OUTBOX_MAX_ATTEMPTS раз подряд, откладывается
+-- (failed_at) и больше не держит следующие события своего заказа. Отложенные
+-- строки не удаляются: их разбирают вручную и возвращают в очередь, сбросив
+-- failed_at и attempts.
+
+ALTER TABLE public.outbox ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ; -- NULL — не отложено
+
+DROP INDEX IF EXISTS public.idx_outbox_pending;
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON public.outbox(id) WHERE published_at IS NULL AND failed_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE public.order_status_history DROP COLUMN IF EXISTS chrt_id;
@@ -0,0 +1,4 @@
+-- Позиция заказа определяется парой (rid, chrt_id): rid внутри заказа может
+-- повторяться. История статусов позиций запоминает и chrt_id.
+
+ALTER TABLE public.order_status_history ADD COLUMN IF NOT EXISTS chrt_id BIGINT; -- NULL — статус заказа или запись до 0009
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// Типы событий о заказах, которые сервис публикует через outbox; тип лежит
+// и в теле, и в заголовке HeaderMessageType.
+const (
+	EventOrderStored  = "order.stored"  // заказ записан впервые
+	EventOrderUpdated = "order.updated" // заказ перезаписан другой версией
+)
+
+// OrderEvent — тело события о заказе. Сам заказ не передаётся: его читают
+// через API по order_uid.
+type OrderEvent struct {
+	Type        string    `json:"type"`
+	OrderUID    string    `json:"order_uid"`
+	TrackNumber string    `json:"track_number"`
+	CustomerID  string    `json:"customer_id"`
+	PayloadHash string    `json:"payload_hash"` // отпечаток записанной версии
+	OccurredAt  time.Time `json:"occurred_at"`
+}
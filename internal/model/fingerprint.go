@@ -0,0 +1,34 @@
+package model
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+)
+
+// Fingerprint — sha256 канонического JSON заказа. Нужен, чтобы отличить
+// повторную доставку того же сообщения от нового содержимого с тем же order_uid.
+// Порядок товаров и часовой пояс date_created на отпечаток не влияют.
+func (o *Order) Fingerprint() (string, error) {
+	c := *o
+	c.DateCreated = c.DateCreated.UTC()
+	c.Delivery.OrderID = c.OrderUID
+	c.Payment.OrderID = c.OrderUID
+
+	c.Items = slices.Clone(o.Items)
+	for i := range c.Items {
+		c.Items[i].OrderID = c.OrderUID
+	}
+	slices.SortFunc(c.Items, func(a, b Item) int {
+		return cmp.Or(cmp.Compare(a.ChrtID, b.ChrtID), cmp.Compare(a.RID, b.RID))
+	})
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -16,32 +16,32 @@ type Delivery struct {
 }
 
 type Payment struct {
-	OrderID      string  `db:"order_id"      json:"order_uid"`
-	Transaction  string  `db:"transaction"   json:"transaction"` // UNIQUE
-	RequestID    string  `db:"request_id"    json:"request_id"`
-	Currency     string  `db:"currency"      json:"currency"`
-	Provider     string  `db:"provider"      json:"provider"`
-	Amount       float64 `db:"amount"        json:"amount"`
-	PaymentDT    int64   `db:"payment_dt"    json:"payment_dt"`
-	Bank         string  `db:"bank"          json:"bank"`
-	DeliveryCost int     `db:"delivery_cost" json:"delivery_cost"`
-	GoodsTotal   int     `db:"goods_total"   json:"goods_total"`
-	CustomFee    int     `db:"custom_fee"    json:"custom_fee"`
+	OrderID      string `db:"order_id"            json:"order_uid"`
+	Transaction  string `db:"transaction"         json:"transaction"` // UNIQUE
+	RequestID    string `db:"request_id"          json:"request_id"`
+	Currency     string `db:"currency"            json:"currency"` // валюта всех сумм заказа
+	Provider     string `db:"provider"            json:"provider"`
+	Amount       Money  `db:"amount_minor"        json:"amount"`
+	PaymentDT    int64  `db:"payment_dt"          json:"payment_dt"`
+	Bank         string `db:"bank"                json:"bank"`
+	DeliveryCost Money  `db:"delivery_cost_minor" json:"delivery_cost"`
+	GoodsTotal   Money  `db:"goods_total_minor"   json:"goods_total"`
+	CustomFee    Money  `db:"custom_fee_minor"    json:"custom_fee"`
 }
 
 type Item struct {
-	OrderID     string `db:"order_id"     json:"order_uid"`
-	ChrtID      int64  `db:"chrt_id"      json:"chrt_id"`
-	TrackNumber string `db:"track_number" json:"track_number"`
-	Price       int    `db:"price"        json:"price"`
-	RID         string `db:"rid"          json:"rid"`
-	Name        string `db:"name"         json:"name"`
-	Sale        int    `db:"sale"         json:"sale"`
-	Size        string `db:"size"         json:"size"`
-	TotalPrice  int    `db:"total_price"  json:"total_price"`
-	NmID        int64  `db:"nm_id"        json:"nm_id"`
-	Brand       string `db:"brand"        json:"brand"`
-	Status      int    `db:"status"       json:"status"`
+	OrderID     string     `db:"order_id"          json:"order_uid"`
+	ChrtID      int64      `db:"chrt_id"           json:"chrt_id"`
+	TrackNumber string     `db:"track_number"      json:"track_number"`
+	Price       Money      `db:"price_minor"       json:"price"`
+	RID         string     `db:"rid"               json:"rid"`
+	Name        string     `db:"name"              json:"name"`
+	Sale        int        `db:"sale"              json:"sale"`
+	Size        string     `db:"size"              json:"size"`
+	TotalPrice  Money      `db:"total_price_minor" json:"total_price"`
+	NmID        int64      `db:"nm_id"             json:"nm_id"`
+	Brand       string     `db:"brand"             json:"brand"`
+	Status      ItemStatus `db:"status"            json:"status"`
 }
 type Order struct {
 	OrderUID          string    `db:"order_uid"          json:"order_uid"`
@@ -57,5 +57,6 @@ type Order struct {
 	ShardKey          string    `db:"shardkey"           json:"shardkey"`
 	SmID              int       `db:"sm_id"              json:"sm_id"`
 	DateCreated       time.Time `db:"date_created"       json:"date_created"`
+	Status            Status    `db:"status"             json:"status,omitempty"` // пусто во входящем заказе — created
 	OofShard          string    `db:"oof_shard"          json:"oof_shard"`
 }
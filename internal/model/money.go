@@ -0,0 +1,174 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"L0/internal/refdata"
+)
+
+// Money — сумма в минимальных единицах валюты (копейках, центах) и код
+// валюты ISO 4217. Суммы складываются и сравниваются как целые, без потерь
+// float64.
+//
+// В JSON сумма — число в единицах валюты, как и раньше ("amount": 18.17):
+// валюта заказа одна и лежит в payment.currency. Поэтому Money разбирается
+// в два шага: UnmarshalJSON запоминает десятичную запись, а Order.UnmarshalJSON
+// переводит её в минимальные единицы, когда валюта уже известна.
+type Money struct {
+	Minor    int64
+	Currency string
+
+	raw string // десятичная запись из JSON, ещё не переведённая в Minor
+}
+
+// NewMoney — сумма из целого числа единиц валюты (453 RUB → 45300).
+func NewMoney(units int64, currency string) Money {
+	return Money{Minor: units * pow10(Digits(currency)), Currency: currency}
+}
+
+// Digits — знаков после запятой у валюты; для неизвестной — 2, чтобы заказ
+// с опечаткой в валюте дошёл до проверки и получил нарушение iso4217.
+func Digits(currency string) int {
+	if d, ok := refdata.MinorUnits(currency); ok {
+		return d
+	}
+	return 2
+}
+
+func (m Money) Add(o Money) Money {
+	m.Minor += o.Minor
+	return m
+}
+
+// Decimal — сумма в единицах валюты со всеми знаками: "18.10", "1817", "-0.05".
+func (m Money) Decimal() string {
+	d := Digits(m.Currency)
+	s := strconv.FormatInt(m.Minor, 10)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if d > 0 {
+		if len(s) <= d {
+			s = strings.Repeat("0", d-len(s)+1) + s
+		}
+		s = s[:len(s)-d] + "." + s[len(s)-d:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// String — "18.10 RUB".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// MarshalJSON пишет число без хвостовых нулей: 1817 и 18.1 выглядят так же,
+// как их писал float64, и отпечатки старых заказов не меняются.
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.Decimal()
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return []byte(s), nil
+}
+
+// UnmarshalJSON принимает число (1817, 18.17) или строку с ним ("18.17").
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("money: want a number, got %s", data)
+	}
+	if _, ok := new(big.Rat).SetString(n.String()); !ok {
+		return fmt.Errorf("money: %q is not a number", n)
+	}
+	*m = Money{raw: n.String()}
+	return nil
+}
+
+// resolve переводит запись из JSON в минимальные единицы валюты currency.
+// Больше знаков после запятой, чем у валюты, — ошибка: округлять деньги молча нельзя.
+func (m *Money) resolve(currency string) error {
+	m.Currency = currency
+	if m.raw == "" {
+		return nil
+	}
+	raw := m.raw
+	m.raw = ""
+
+	d := Digits(currency)
+	r, _ := new(big.Rat).SetString(raw)
+	r.Mul(r, new(big.Rat).SetInt64(pow10(d)))
+	if !r.IsInt() {
+		return fmt.Errorf("%s has more than %d fractional digits allowed for %s", raw, d, currency)
+	}
+	if !r.Num().IsInt64() {
+		return fmt.Errorf("%s is out of range", raw)
+	}
+	m.Minor = r.Num().Int64()
+	return nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for range n {
+		p *= 10
+	}
+	return p
+}
+
+// UnmarshalJSON разбирает заказ и переводит все суммы в минимальные единицы
+// валюты payment.currency.
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type plain Order // без методов, иначе рекурсия
+	if err := json.Unmarshal(data, (*plain)(o)); err != nil {
+		return err
+	}
+
+	for _, f := range o.moneyFields() {
+		if err := f.m.resolve(o.Payment.Currency); err != nil {
+			return fmt.Errorf("%s: %w", f.field, err)
+		}
+	}
+	return nil
+}
+
+// SyncCurrency проставляет валюту оплаты во все суммы заказа — после чтения
+// из БД, где валюта хранится только в payments.
+func (o *Order) SyncCurrency() {
+	for _, f := range o.moneyFields() {
+		f.m.Currency = o.Payment.Currency
+	}
+}
+
+type moneyField struct {
+	field string
+	m     *Money
+}
+
+// moneyFields — все суммы заказа с путями к полям в терминах JSON.
+func (o *Order) moneyFields() []moneyField {
+	fs := []moneyField{
+		{"payment.amount", &o.Payment.Amount},
+		{"payment.delivery_cost", &o.Payment.DeliveryCost},
+		{"payment.goods_total", &o.Payment.GoodsTotal},
+		{"payment.custom_fee", &o.Payment.CustomFee},
+	}
+	for i := range o.Items {
+		fs = append(fs,
+			moneyField{fmt.Sprintf("items[%d].price", i), &o.Items[i].Price},
+			moneyField{fmt.Sprintf("items[%d].total_price", i), &o.Items[i].TotalPrice})
+	}
+	return fs
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMoneyParse(t *testing.T) {
+	tests := []struct {
+		json     string
+		currency string
+		minor    int64
+		err      string // подстрока ошибки; пусто — без ошибки
+	}{
+		{json: `1817`, currency: "RUB", minor: 181700},
+		{json: `18.1`, currency: "RUB", minor: 1810},
+		{json: `18.17`, currency: "USD", minor: 1817},
+		{json: `0.1`, currency: "RUB", minor: 10}, // float64 дал бы 0.1000000000000000055…
+		{json: `"18.17"`, currency: "RUB", minor: 1817},
+		{json: `"1817"`, currency: "RUB", minor: 181700},
+		{json: `1e2`, currency: "RUB", minor: 10000},
+		{json: `-0.05`, currency: "RUB", minor: -5},
+		{json: `"-12"`, currency: "EUR", minor: -1200},
+		{json: `18.171`, currency: "RUB", err: "more than 2 fractional digits"},
+		{json: `18.170`, currency: "RUB", minor: 1817}, // нули в хвосте — не лишняя точность
+		{json: `1817`, currency: "JPY", minor: 1817},
+		{json: `18.5`, currency: "JPY", err: "more than 0 fractional digits"},
+		{json: `1.234`, currency: "KWD", minor: 1234},
+		{json: `1.2345`, currency: "KWD", err: "more than 3 fractional digits"},
+		{json: `1.5`, currency: "XXX", minor: 150}, // неизвестная валюта — 2 знака
+		{json: `92233720368547758.08`, currency: "RUB", err: "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.json+" "+tt.currency, func(t *testing.T) {
+			var m Money
+			if err := json.Unmarshal([]byte(tt.json), &m); err != nil {
+				t.Fatalf("UnmarshalJSON: %v", err)
+			}
+			err := m.resolve(tt.currency)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("resolve error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if m.Minor != tt.minor || m.Currency != tt.currency {
+				t.Errorf("got %d %s, want %d %s", m.Minor, m.Currency, tt.minor, tt.currency)
+			}
+		})
+	}
+}
+
+func TestMoneyUnmarshalRejects(t *testing.T) {
+	for _, in := range []string{`"abc"`, `true`, `{}`, `[1]`, `""`} {
+		var m Money
+		if err := json.Unmarshal([]byte(in), &m); err == nil {
+			t.Errorf("UnmarshalJSON(%s) = nil error, want an error", in)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		m       Money
+		decimal string
+		json    string
+	}{
+		{Money{Minor: 181700, Currency: "RUB"}, "1817.00", "1817"},
+		{Money{Minor: 1810, Currency: "RUB"}, "18.10", "18.1"},
+		{Money{Minor: 1817, Currency: "RUB"}, "18.17", "18.17"},
+		{Money{Minor: 5, Currency: "RUB"}, "0.05", "0.05"},
+		{Money{Minor: -5, Currency: "RUB"}, "-0.05", "-0.05"},
+		{Money{Minor: -181700, Currency: "RUB"}, "-1817.00", "-1817"},
+		{Money{Minor: 0, Currency: "RUB"}, "0.00", "0"},
+		{Money{Minor: 1817, Currency: "JPY"}, "1817", "1817"},
+		{Money{Minor: 1230, Currency: "KWD"}, "1.230", "1.23"},
+		{Money{Minor: 1, Currency: "KWD"}, "0.001", "0.001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.m.String(), func(t *testing.T) {
+			if got := tt.m.Decimal(); got != tt.decimal {
+				t.Errorf("Decimal = %q, want %q", got, tt.decimal)
+			}
+			data, err := json.Marshal(tt.m)
+			if err != nil {
+				t.Fatalf("MarshalJSON: %v", err)
+			}
+			if string(data) != tt.json {
+				t.Errorf("MarshalJSON = %s, want %s", data, tt.json)
+			}
+		})
+	}
+}
+
+// Заказ, прочитанный из JSON, записанный обратно и прочитанный ещё раз
+// (или поднятый из БД в минимальных единицах), даёт тот же отпечаток:
+// иначе повторное сообщение считалось бы новой версией заказа.
+func TestMoneyRoundTripKeepsFingerprint(t *testing.T) {
+	for _, currency := range []string{"RUB", "JPY", "KWD"} {
+		t.Run(currency, func(t *testing.T) {
+			in := `{
+				"order_uid": "b563feb7b2b84b6test",
+				"payment": {"currency": "` + currency + `", "amount": 1817, "delivery_cost": 15,
+					"goods_total": 317, "custom_fee": 0},
+				"items": [{"chrt_id": 9934930, "price": 453, "rid": "ab4219087a764ae0btest",
+					"sale": 30, "total_price": 317}]
+			}`
+			if currency != "JPY" {
+				in = strings.Replace(in, `"delivery_cost": 15`, `"delivery_cost": 15.5`, 1)
+			}
+
+			var o Order
+			if err := json.Unmarshal([]byte(in), &o); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			want, err := o.Fingerprint()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := json.Marshal(o)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var again Order
+			if err := json.Unmarshal(data, &again); err != nil {
+				t.Fatalf("unmarshal again: %v", err)
+			}
+			if got, _ := again.Fingerprint(); got != want {
+				t.Errorf("fingerprint after JSON round trip changed:\n%s", data)
+			}
+
+			// из БД суммы приходят в минимальных единицах без валюты
+			fromDB := o
+			fromDB.Payment.Amount = Money{Minor: o.Payment.Amount.Minor}
+			fromDB.Items = []Item{o.Items[0]}
+			fromDB.Items[0].Price = Money{Minor: o.Items[0].Price.Minor}
+			fromDB.SyncCurrency()
+			if got, _ := fromDB.Fingerprint(); got != want {
+				t.Error("fingerprint of the order read from the DB changed")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Status — состояние заказа в жизненном цикле.
+//
+//	created → paid → assembling → shipped → delivered → returned
+//	   └────────┴──────────┴──→ cancelled      └──────→ returned
+type Status string
+
+const (
+	StatusCreated    Status = "created"    // принят сервисом
+	StatusPaid       Status = "paid"       // оплачен
+	StatusAssembling Status = "assembling" // собирается на складе
+	StatusShipped    Status = "shipped"    // передан в доставку
+	StatusDelivered  Status = "delivered"  // получен покупателем
+	StatusCancelled  Status = "cancelled"  // отменён до отгрузки
+	StatusReturned   Status = "returned"   // возвращён после отгрузки
+)
+
+// transitions — разрешённые переходы; состояний без исходящих переходов
+// (cancelled, returned) здесь нет — они конечные.
+var transitions = map[Status][]Status{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered, StatusReturned},
+	StatusDelivered:  {StatusReturned},
+}
+
+var statuses = []Status{
+	StatusCreated, StatusPaid, StatusAssembling, StatusShipped,
+	StatusDelivered, StatusCancelled, StatusReturned,
+}
+
+func (s Status) Valid() bool {
+	return slices.Contains(statuses, s)
+}
+
+// Terminal — из состояния никуда не перейти.
+func (s Status) Terminal() bool {
+	return s.Valid() && len(transitions[s]) == 0
+}
+
+// Next — куда можно перейти из s.
+func (s Status) Next() []Status {
+	return slices.Clone(transitions[s])
+}
+
+var ErrInvalidTransition = errors.New("status transition is not allowed")
+
+// Transition проверяет переход from → to; ошибка оборачивает ErrInvalidTransition.
+func Transition(from, to Status) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, to)
+	}
+	if !slices.Contains(transitions[from], to) {
+		return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+	}
+	return nil
+}
+
+// ItemStatus — статус позиции. В JSON и БД это числовой код (так его
+// присылает источник заказа, 202), каждому коду соответствует Status,
+// и позиции ходят по тем же переходам, что и заказ.
+type ItemStatus int
+
+const (
+	ItemCreated    ItemStatus = 202
+	ItemPaid       ItemStatus = 203
+	ItemAssembling ItemStatus = 204
+	ItemShipped    ItemStatus = 205
+	ItemDelivered  ItemStatus = 206
+	ItemCancelled  ItemStatus = 207
+	ItemReturned   ItemStatus = 208
+)
+
+var itemStatuses = map[ItemStatus]Status{
+	ItemCreated:    StatusCreated,
+	ItemPaid:       StatusPaid,
+	ItemAssembling: StatusAssembling,
+	ItemShipped:    StatusShipped,
+	ItemDelivered:  StatusDelivered,
+	ItemCancelled:  StatusCancelled,
+	ItemReturned:   StatusReturned,
+}
+
+// Status — состояние, соответствующее коду; ok=false для неизвестного кода.
+func (s ItemStatus) Status() (st Status, ok bool) {
+	st, ok = itemStatuses[s]
+	return st, ok
+}
+
+func (s ItemStatus) String() string {
+	if st, ok := s.Status(); ok {
+		return string(st)
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// ItemStatusOf — код позиции для состояния st.
+func ItemStatusOf(st Status) (ItemStatus, bool) {
+	for code, s := range itemStatuses {
+		if s == st {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// Тип сообщения в топике заказов задаётся заголовком; без заголовка —
+// заказ целиком (model.Order).
+const (
+	HeaderMessageType = "x-message-type"
+	MessageTypeStatus = "order.status" // StatusUpdate
+)
+
+// StatusUpdate — событие смены статуса заказа или одной его позиции.
+type StatusUpdate struct {
+	OrderUID  string    `json:"order_uid"`
+	RID       string    `json:"rid,omitempty"`     // пусто — статус заказа, иначе — позиции с этим rid
+	ChrtID    int64     `json:"chrt_id,omitempty"` // с rid: нужен, если rid в заказе не уникален
+	Status    Status    `json:"status"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"` // когда статус сменился у источника; пусто — время приёма
+}
+
+// Validate возвращает *ValidationError со всеми нарушениями или nil.
+func (u *StatusUpdate) Validate() error {
+	var v validator
+	v.required("order_uid", u.OrderUID)
+	v.required("status", string(u.Status))
+	if u.Status != "" && !u.Status.Valid() {
+		v.add("status", RuleStatus, u.Status, "is not a known status")
+	}
+	if u.ChrtID != 0 && u.RID == "" {
+		v.add("rid", RuleRequired, nil, "is empty while chrt_id is set")
+	}
+	return v.err()
+}
+
+// StatusChange — запись истории статусов.
+type StatusChange struct {
+	RID       string    `json:"rid,omitempty"`     // пусто — статус заказа
+	ChrtID    int64     `json:"chrt_id,omitempty"` // у позиции; пусто в записях до миграции 0009
+	From      Status    `json:"from,omitempty"`    // пусто — первая запись (заказ принят)
+	To        Status    `json:"to"`
+	Reason    string    `json:"reason,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -1,39 +1,240 @@
 package model
 
 import (
-	"errors"
 	"fmt"
+	"math"
 	"regexp"
+	"strings"
+
+	"L0/internal/refdata"
 )
 
-func (o *Order) Validate() error {
-	if o.OrderUID == "" {
-		return errors.New("order_uid is empty")
+// Правила проверки — значение поля Rule в Violation.
+const (
+	RuleRequired    = "required"     // поле пустое
+	RuleNonNegative = "non_negative" // число меньше нуля
+	RuleEmail       = "email"        // строка не похожа на email
+	RuleRange       = "range"        // число вне допустимого диапазона
+	RuleMinItems    = "min_items"    // в заказе нет товаров
+	RuleMatchOrder  = "match_order"  // поле товара расходится с тем же полем заказа
+	RuleSum         = "sum"          // итог не сходится с составляющими
+	RuleCurrency    = "iso4217"      // не код валюты ISO 4217
+	RuleLocale      = "bcp47"        // не тег языка BCP 47
+	RulePhone       = "e164"         // номер не приводится к E.164
+	RulePostalCode  = "postal_code"  // индекс не в формате страны
+	RuleAllowed     = "allowed"      // значения нет в справочнике допустимых
+	RuleStatus      = "status"       // неизвестный статус заказа или позиции
+)
+
+// Режимы проверки денежных инвариантов.
+const (
+	FinanceStrict = "strict" // расхождение — нарушение, заказ отвергается
+	FinanceWarn   = "warn"   // расхождение — предупреждение, заказ принимается
+	FinanceOff    = "off"    // не проверять
+)
+
+// Rules — настраиваемая часть проверки.
+type Rules struct {
+	// FinanceMode — что делать, если суммы не сходятся:
+	//   items[].total_price = price - price*sale/100
+	//   payment.goods_total = Σ items[].total_price
+	//   payment.amount      = goods_total + delivery_cost + custom_fee
+	FinanceMode string
+	// Tolerance — допустимое расхождение сумм в единицах валюты
+	// (округление скидки у разных источников расходится на копейки/единицы);
+	// сравнение идёт в минимальных единицах валюты заказа.
+	Tolerance float64
+	// DefaultCountry — страна (ISO 3166-1 alpha-2) для телефонов без кода
+	// страны; по ней же проверяется индекс, если страну не дал телефон.
+	DefaultCountry string
+	// Allowed — допустимые delivery_service, payment.provider и payment.bank;
+	// nil — любые.
+	Allowed *refdata.Allowed
+}
+
+// DefaultRules — правила, которые применяет Validate.
+var DefaultRules = Rules{FinanceMode: FinanceStrict, Tolerance: 1, DefaultCountry: "RU"}
+
+// ParseFinanceMode проверяет значение из конфигурации.
+func ParseFinanceMode(s string) (string, error) {
+	switch s {
+	case FinanceStrict, FinanceWarn, FinanceOff:
+		return s, nil
 	}
-	if o.TrackNumber == "" {
-		return errors.New("track_number is empty")
+	return "", fmt.Errorf("unknown finance check mode %q (want strict, warn or off)", s)
+}
+
+// Violation — одно нарушение: путь к полю в терминах JSON (items[2].price),
+// правило и значение, которое его нарушило.
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return v.Field + ": " + v.Message
+}
+
+// ValidationError собирает все нарушения заказа, а не только первое.
+// Достаётся из цепочки через errors.As.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
 	}
-	if o.CustomerID == "" {
-		return errors.New("customer_id is empty")
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return fmt.Sprintf("%d violations: %s", len(parts), strings.Join(parts, "; "))
+}
+
+// validator копит нарушения; err() возвращает nil, если их нет.
+type validator struct {
+	violations []Violation
+}
+
+func (v *validator) add(field, rule string, value any, msg string) {
+	v.violations = append(v.violations, Violation{Field: field, Rule: rule, Value: value, Message: msg})
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.add(field, RuleRequired, nil, "is empty")
 	}
-	if o.Payment.Amount < 0 {
-		return fmt.Errorf("payment.amount is negative: %v", o.Payment.Amount)
+}
+
+func (v *validator) nonNegative(field string, value Money) {
+	if value.Minor < 0 {
+		v.add(field, RuleNonNegative, value, "is negative")
 	}
-	if o.Payment.Transaction == "" {
-		return errors.New("payment.transaction is empty")
+}
+
+func (v *validator) err() error {
+	if len(v.violations) == 0 {
+		return nil
 	}
+	return &ValidationError{Violations: v.violations}
+}
+
+// Validate проверяет заказ по DefaultRules и возвращает *ValidationError
+// со всеми нарушениями или nil.
+func (o *Order) Validate() error {
+	_, err := o.Check(DefaultRules)
+	return err
+}
+
+// Check проверяет заказ по правилам r. Нарушения денежных инвариантов
+// в режиме warn возвращаются как warnings и ошибкой не считаются.
+func (o *Order) Check(r Rules) (warnings []Violation, err error) {
+	var v validator
+
+	v.required("order_uid", o.OrderUID)
+	v.required("track_number", o.TrackNumber)
+	v.required("customer_id", o.CustomerID)
+	v.nonNegative("payment.amount", o.Payment.Amount)
+	v.required("payment.transaction", o.Payment.Transaction)
 	if o.Delivery.Email != "" && !isValidEmail(o.Delivery.Email) {
-		return fmt.Errorf("invalid email: %s", o.Delivery.Email)
+		v.add("delivery.email", RuleEmail, o.Delivery.Email, "is not a valid email")
+	}
+	if o.Status != "" && !o.Status.Valid() {
+		v.add("status", RuleStatus, o.Status, "is not a known status")
+	}
+	o.checkReference(&v, r)
+	if len(o.Items) == 0 {
+		v.add("items", RuleMinItems, nil, "order has no items")
 	}
 	for i, item := range o.Items {
-		if item.Price < 0 {
-			return fmt.Errorf("item[%d].price negative", i)
+		v.nonNegative(fmt.Sprintf("items[%d].price", i), item.Price)
+		if item.Sale < 0 || item.Sale > 100 {
+			v.add(fmt.Sprintf("items[%d].sale", i), RuleRange, item.Sale, "must be within 0..100")
+		}
+		if _, ok := item.Status.Status(); !ok {
+			v.add(fmt.Sprintf("items[%d].status", i), RuleStatus, item.Status, "is not a known item status code")
 		}
-		if item.TrackNumber == "" {
-			return fmt.Errorf("item[%d].track_number empty", i)
+		v.required(fmt.Sprintf("items[%d].track_number", i), item.TrackNumber)
+		if item.TrackNumber != "" && o.TrackNumber != "" && item.TrackNumber != o.TrackNumber {
+			v.add(fmt.Sprintf("items[%d].track_number", i), RuleMatchOrder, item.TrackNumber,
+				fmt.Sprintf("differs from order track_number %q", o.TrackNumber))
 		}
 	}
-	return nil
+
+	if r.FinanceMode == FinanceOff {
+		return nil, v.err()
+	}
+	fin := o.checkFinance(r.Tolerance)
+	if r.FinanceMode == FinanceWarn {
+		return fin, v.err()
+	}
+	v.violations = append(v.violations, fin...)
+	return nil, v.err()
+}
+
+// checkReference проверяет поля по справочникам: валюту, локаль, телефон,
+// индекс и допустимые значения. Пустые поля не проверяются — это дело required.
+func (o *Order) checkReference(v *validator, r Rules) {
+	if c := o.Payment.Currency; c != "" && !refdata.IsCurrency(c) {
+		v.add("payment.currency", RuleCurrency, c, "is not an ISO 4217 currency code")
+	}
+	if l := o.Locale; l != "" && !refdata.IsLocale(l) {
+		v.add("locale", RuleLocale, l, "is not a BCP 47 language tag")
+	}
+
+	country := r.DefaultCountry
+	if p := o.Delivery.Phone; p != "" {
+		_, phoneCountry, err := refdata.NormalizePhone(p, r.DefaultCountry)
+		if err != nil {
+			v.add("delivery.phone", RulePhone, p, "is not an E.164 phone number: "+err.Error())
+		} else {
+			country = phoneCountry
+		}
+	}
+	if z := o.Delivery.Zip; z != "" && !refdata.IsPostalCode(country, z) {
+		v.add("delivery.zip", RulePostalCode, z, fmt.Sprintf("is not a valid postal code for %s", country))
+	}
+
+	if r.Allowed == nil {
+		return
+	}
+	allowed := func(field string, list []string, value string) {
+		if value != "" && !refdata.Permits(list, value) {
+			v.add(field, RuleAllowed, value, "is not in the list of allowed values")
+		}
+	}
+	allowed("delivery_service", r.Allowed.DeliveryService, o.DeliveryService)
+	allowed("payment.provider", r.Allowed.Provider, o.Payment.Provider)
+	allowed("payment.bank", r.Allowed.Bank, o.Payment.Bank)
+}
+
+// checkFinance сверяет суммы так же, как их считает продюсер (скидка
+// отбрасывает дробную часть минимальной единицы), с допуском tol единиц валюты.
+func (o *Order) checkFinance(tol float64) []Violation {
+	var v validator
+	cur := o.Payment.Currency
+	tolMinor := int64(math.Round(tol * float64(pow10(Digits(cur)))))
+	mismatch := func(field string, got, want Money, formula string) {
+		diff := got.Minor - want.Minor
+		if diff > tolMinor || -diff > tolMinor {
+			v.add(field, RuleSum, got, fmt.Sprintf("expected %s = %s", formula, want))
+		}
+	}
+
+	goods := Money{Currency: cur}
+	for i, it := range o.Items {
+		want := Money{Minor: it.Price.Minor - it.Price.Minor*int64(it.Sale)/100, Currency: cur}
+		mismatch(fmt.Sprintf("items[%d].total_price", i), it.TotalPrice, want, "price - price*sale/100")
+		goods = goods.Add(it.TotalPrice)
+	}
+	p := o.Payment
+	mismatch("payment.goods_total", p.GoodsTotal, goods, "sum of items[].total_price")
+	mismatch("payment.amount", p.Amount, p.GoodsTotal.Add(p.DeliveryCost).Add(p.CustomFee),
+		"goods_total + delivery_cost + custom_fee")
+	return v.violations
 }
 
 // очень простая проверка email
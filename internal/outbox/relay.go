@@ -0,0 +1,379 @@
+package outbox
+
+import (
+	"L0/internal/lifecycle"
+	"L0/internal/logging"
+	"L0/internal/metrics"
+	"L0/internal/model"
+	"L0/internal/retry"
+	"L0/internal/tracing"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	kafka "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// cleanupInterval — как часто удаляются опубликованные события старше Retention.
+const cleanupInterval = time.Minute
+
+// relayLock — ключ advisory-блокировки, под которой публикует пачку одна
+// реплика ретранслятора.
+const relayLock int64 = 0x6f7574626f78 // "outbox"
+
+// HeaderEventID — id строки outbox: по нему получатель отбрасывает повторы
+// (доставка «хотя бы один раз»).
+const HeaderEventID = "x-event-id"
+
+type Options struct {
+	Topic        string        // куда публиковать события
+	PollInterval time.Duration // пауза между опросами, когда outbox пуст
+	BatchSize    int           // событий за один опрос
+	Retention    time.Duration // сколько хранить опубликованные события (0 — при ближайшей очистке)
+	Retry        retry.Policy  // задержка после неудачной публикации; MaxAttempts не ограничивает
+	MaxAttempts  int           // после стольких отказов брокера событие откладывается (failed_at)
+}
+
+// Relay публикует события из таблицы outbox в Kafka. Событие помечается
+// опубликованным только после подтверждения брокера, поэтому при сбое между
+// публикацией и пометкой оно уйдёт ещё раз. Реплики публикуют по очереди:
+// пачку берёт та, что захватила advisory-блокировку relayLock, иначе
+// события одного заказа из разных пачек могли бы уйти не по порядку.
+type Relay struct {
+	pool *pgxpool.Pool
+	w    *kafka.Writer
+	opts Options
+
+	stopCtx context.Context
+	stop    context.CancelFunc
+}
+
+func New(pool *pgxpool.Pool, brokers []string, opts Options) *Relay {
+	stopCtx, stop := context.WithCancel(context.Background())
+	return &Relay{
+		pool: pool,
+		w: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        opts.Topic,
+			Balancer:     &kafka.Hash{}, // события одного заказа — в одну партицию по порядку
+			RequiredAcks: kafka.RequireAll,
+		},
+		opts:    opts,
+		stopCtx: stopCtx,
+		stop:    stop,
+	}
+}
+
+// Component — ретранслятор для lifecycle.Manager: после Stop он публикует
+// всё, что уже есть в outbox, и выходит; отмена ctx прерывает публикацию
+// (события останутся в outbox до следующего запуска).
+func (r *Relay) Component() lifecycle.Component {
+	return lifecycle.Component{Name: "outbox-relay", Run: r.run, Stop: func(context.Context) error {
+		r.stop()
+		return nil
+	}}
+}
+
+func (r *Relay) run(ctx context.Context) error {
+	lg := logging.FromContext(ctx)
+	failures := 0
+	var cleaned time.Time
+	for {
+		n, err := r.publishBatch(ctx)
+		if r.stopCtx.Err() != nil && (err != nil || n < r.opts.BatchSize) {
+			return nil
+		}
+		delay := r.opts.PollInterval
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			// не чаще опроса: при нулевой задержке RETRY_* цикл крутился бы вхолостую
+			delay = max(r.opts.Retry.Backoff(failures), r.opts.PollInterval)
+			lg.Warn("не удалось опубликовать события outbox, повторю", "failures", failures, "retry_in", delay, logging.Err(err))
+		case n == r.opts.BatchSize:
+			failures = 0
+			delay = 0 // в outbox есть ещё
+		default:
+			failures = 0
+		}
+
+		if time.Since(cleaned) >= cleanupInterval {
+			if err := r.cleanup(ctx); err != nil && ctx.Err() == nil {
+				lg.Warn("не удалось удалить опубликованные события outbox", logging.Err(err))
+			}
+			cleaned = time.Now()
+		}
+		r.updatePending(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stopCtx.Done():
+			// ещё один проход: забрать то, что успели записать воркеры
+		case <-time.After(delay):
+		}
+	}
+}
+
+// event — строка outbox, взятая на публикацию.
+type event struct {
+	id      int64
+	typ     string
+	key     string
+	payload []byte
+	headers map[string]string
+}
+
+// publishBatch публикует до BatchSize самых старых неопубликованных событий.
+// Возвращает, сколько событий взято, и ошибку, если хоть одно не ушло.
+// Пока публикует другая реплика, ничего не берёт.
+func (r *Relay) publishBatch(ctx context.Context) (int, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// блокировка транзакции: снимается коммитом или откатом
+	var locked bool
+	if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, relayLock).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("outbox lock: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, event_type, aggregate_id, payload, headers
+		FROM outbox
+		WHERE published_at IS NULL AND failed_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE
+	`, r.opts.BatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("select outbox: %w", err)
+	}
+	events, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (event, error) {
+		var e event
+		err := row.Scan(&e.id, &e.typ, &e.key, &e.payload, &e.headers)
+		return e, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("scan outbox: %w", err)
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	msgs := make([]kafka.Message, 0, len(events))
+	ends := make([]func(error), 0, len(events))
+	for _, e := range events {
+		m := kafka.Message{
+			Key:   []byte(e.key),
+			Value: e.payload,
+			Headers: []kafka.Header{
+				{Key: model.HeaderMessageType, Value: []byte(e.typ)},
+				{Key: HeaderEventID, Value: []byte(strconv.FormatInt(e.id, 10))},
+			},
+		}
+		// публикация продолжает трассу записи заказа
+		spanCtx, span := tracing.Start(tracing.ExtractMap(ctx, e.headers), "kafka.publish",
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.destination.name", r.opts.Topic),
+			attribute.String("messaging.kafka.message.key", e.key),
+			attribute.Int64("outbox.event_id", e.id),
+		)
+		tracing.Inject(spanCtx, &m)
+		msgs = append(msgs, m)
+		ends = append(ends, func(err error) { tracing.End(span, err) })
+	}
+
+	writeErr := r.w.WriteMessages(ctx, msgs...)
+	errs := eventErrors(writeErr, msgs)
+
+	// после первого не ушедшего события ключа его следующие события не
+	// помечаем, даже если брокер их принял: они уйдут снова вслед за ним, и
+	// получатель увидит события заказа в исходном порядке (повторы он отбросит)
+	var (
+		published, failed []int64
+		reasons           []string
+		rejected          []bool
+	)
+	failedKeys := make(map[string]struct{})
+	for i, e := range events {
+		err := errs[i]
+		ends[i](err)
+		_, blocked := failedKeys[e.key]
+		switch {
+		case errors.Is(err, errNotSent):
+			failedKeys[e.key] = struct{}{}
+		case err != nil:
+			failed = append(failed, e.id)
+			reasons = append(reasons, err.Error())
+			rejected = append(rejected, isRejected(err))
+			failedKeys[e.key] = struct{}{}
+		case !blocked:
+			published = append(published, e.id)
+		}
+	}
+
+	if len(published) > 0 {
+		if _, err := tx.Exec(ctx,
+			`UPDATE outbox SET published_at = now() WHERE id = ANY($1)`, published); err != nil {
+			return 0, fmt.Errorf("mark published: %w", err)
+		}
+	}
+	var parked []failedEvent
+	if len(failed) > 0 {
+		// отвергнутое брокером MaxAttempts раз откладывается, чтобы не держать
+		// события своего заказа вечно; сбои связи не считаются — при недоступном
+		// брокере откладывался бы весь outbox
+		rows, err := tx.Query(ctx, `
+			UPDATE outbox o SET
+				attempts   = o.attempts + 1,
+				last_error = f.reason,
+				failed_at  = CASE WHEN f.rejected AND o.attempts + 1 >= $4 THEN now() END
+			FROM unnest($1::bigint[], $2::text[], $3::bool[]) AS f(id, reason, rejected)
+			WHERE o.id = f.id
+			RETURNING o.id, o.aggregate_id, o.attempts, o.last_error, o.failed_at IS NOT NULL
+		`, failed, reasons, rejected, r.opts.MaxAttempts)
+		if err != nil {
+			return 0, fmt.Errorf("mark failed: %w", err)
+		}
+		marked, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (failedEvent, error) {
+			var f failedEvent
+			err := row.Scan(&f.id, &f.key, &f.attempts, &f.reason, &f.parked)
+			return f, err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("mark failed: %w", err)
+		}
+		for _, f := range marked {
+			if f.parked {
+				parked = append(parked, f)
+			}
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+
+	lg := logging.FromContext(ctx)
+	for _, p := range parked {
+		lg.Error("событие outbox отложено: брокер отвергает его",
+			"event_id", p.id, logging.KeyOrderUID, p.key, "attempts", p.attempts, "error", p.reason)
+	}
+	metrics.OutboxParked.Add(float64(len(parked)))
+	metrics.OutboxPublished.Add(float64(len(published)))
+	if writeErr != nil {
+		metrics.OutboxPublishErrors.Add(float64(len(failed)))
+		return len(events), fmt.Errorf("outbox publish to %s: %d of %d failed: %w", r.opts.Topic, len(failed), len(events), writeErr)
+	}
+	lg.Debug("события outbox опубликованы", "events", len(events))
+	return len(events), nil
+}
+
+// failedEvent — не ушедшее событие после пометки в outbox.
+type failedEvent struct {
+	id       int64
+	key      string
+	attempts int
+	reason   string
+	parked   bool // отложено: MaxAttempts отказов брокера
+}
+
+// eventErrors раскладывает ошибку WriteMessages по событиям пачки. Слишком
+// большое сообщение writer отвергает до отправки, не отправив и остальные:
+// ошибка достаётся только ему, а прочие получают errNotSent: они остаются
+// в очереди, не расходуя попыток.
+func eventErrors(err error, msgs []kafka.Message) []error {
+	errs := make([]error, len(msgs))
+	if err == nil {
+		return errs
+	}
+	var perMessage kafka.WriteErrors
+	if errors.As(err, &perMessage) && len(perMessage) == len(msgs) {
+		copy(errs, perMessage)
+		return errs
+	}
+	var tooLarge kafka.MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		id := header(tooLarge.Message, HeaderEventID)
+		for i := range msgs {
+			if header(msgs[i], HeaderEventID) == id {
+				errs[i] = err
+			} else {
+				errs[i] = errNotSent
+			}
+		}
+		return errs
+	}
+	for i := range errs {
+		errs[i] = err
+	}
+	return errs
+}
+
+// errNotSent — событие не отправлялось: writer отверг пачку из-за другого.
+var errNotSent = errors.New("not sent: batch rejected because of another event")
+
+// isRejected — брокер (или writer) отверг само сообщение, и повтор его не
+// пропустит: слишком большое, невалидное, нет прав на топик. Сбои связи и
+// временные ошибки брокера сюда не относятся.
+func isRejected(err error) bool {
+	var tooLarge kafka.MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		return true
+	}
+	var ke kafka.Error
+	return errors.As(err, &ke) && !ke.Temporary()
+}
+
+func header(m kafka.Message, key string) string {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// cleanup удаляет опубликованные события старше Retention.
+func (r *Relay) cleanup(ctx context.Context) error {
+	tag, err := r.pool.Exec(ctx, `
+		DELETE FROM outbox
+		WHERE published_at IS NOT NULL AND published_at < now() - make_interval(secs => $1)
+	`, r.opts.Retention.Seconds())
+	if err != nil {
+		return fmt.Errorf("outbox cleanup: %w", err)
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		logging.FromContext(ctx).Debug("опубликованные события outbox удалены", "events", n)
+	}
+	return nil
+}
+
+func (r *Relay) updatePending(ctx context.Context) {
+	var n int64
+	if err := r.pool.QueryRow(ctx,
+		`SELECT count(*) FROM outbox WHERE published_at IS NULL AND failed_at IS NULL`).Scan(&n); err == nil {
+		metrics.OutboxPending.Set(float64(n))
+	}
+}
+
+func (r *Relay) Topic() string {
+	return r.opts.Topic
+}
+
+func (r *Relay) Close() error {
+	return r.w.Close()
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	kafka "github.com/segmentio/kafka-go"
+)
+
+func TestEventErrors(t *testing.T) {
+	msg := func(id string) kafka.Message {
+		return kafka.Message{Headers: []kafka.Header{{Key: HeaderEventID, Value: []byte(id)}}}
+	}
+	msgs := []kafka.Message{msg("1"), msg("2"), msg("3")}
+	tooLarge := kafka.MessageTooLargeError{Message: msgs[1], Remaining: []kafka.Message{msgs[0], msgs[2]}}
+
+	tests := []struct {
+		name     string
+		err      error
+		want     []error // ожидаемая ошибка события (errors.Is)
+		rejected []bool
+	}{
+		{
+			name: "no error",
+			want: []error{nil, nil, nil},
+		},
+		{
+			name:     "per message",
+			err:      kafka.WriteErrors{nil, kafka.MessageSizeTooLarge, io.ErrUnexpectedEOF},
+			want:     []error{nil, kafka.MessageSizeTooLarge, io.ErrUnexpectedEOF},
+			rejected: []bool{false, true, false},
+		},
+		{
+			name:     "temporary broker error is not a rejection",
+			err:      kafka.WriteErrors{kafka.LeaderNotAvailable, nil, nil},
+			want:     []error{kafka.LeaderNotAvailable, nil, nil},
+			rejected: []bool{false, false, false},
+		},
+		{
+			name:     "too large message holds the rest unsent",
+			err:      tooLarge,
+			want:     []error{errNotSent, kafka.MessageSizeTooLarge, errNotSent},
+			rejected: []bool{false, true, false},
+		},
+		{
+			name:     "whole batch failed",
+			err:      io.ErrUnexpectedEOF,
+			want:     []error{io.ErrUnexpectedEOF, io.ErrUnexpectedEOF, io.ErrUnexpectedEOF},
+			rejected: []bool{false, false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := eventErrors(tt.err, msgs)
+			for i, err := range errs {
+				if (tt.want[i] == nil) != (err == nil) || !errors.Is(err, tt.want[i]) {
+					t.Errorf("event %d: error = %v, want %v", i, err, tt.want[i])
+				}
+				if err != nil && !errors.Is(err, errNotSent) && isRejected(err) != tt.rejected[i] {
+					t.Errorf("event %d: isRejected(%v) = %v", i, err, !tt.rejected[i])
+				}
+			}
+		})
+	}
+}
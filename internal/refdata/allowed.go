@@ -0,0 +1,46 @@
+package refdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Allowed — справочник допустимых значений полей заказа, читается из файла
+// (REFERENCE_ALLOWED_FILE). Пустой или отсутствующий список — поле не
+// ограничено. Пример:
+//
+//	{
+//	  "delivery_service": ["meest", "cdek", "dpd", "ups"],
+//	  "provider": ["wbpay", "bank", "visa", "mc"],
+//	  "bank": ["alpha", "sber", "tinkoff", "vtb"]
+//	}
+type Allowed struct {
+	DeliveryService []string `json:"delivery_service"`
+	Provider        []string `json:"provider"`
+	Bank            []string `json:"bank"`
+}
+
+// LoadAllowed читает справочник; неизвестные ключи — ошибка, чтобы опечатка
+// в имени поля не отключала проверку молча.
+func LoadAllowed(path string) (*Allowed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	var a Allowed
+	if err := dec.Decode(&a); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &a, nil
+}
+
+// Permits — v есть в list или list пуст.
+func Permits(list []string, v string) bool {
+	return len(list) == 0 || slices.Contains(list, v)
+}
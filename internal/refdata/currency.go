@@ -0,0 +1,42 @@
+package refdata
+
+import (
+	"strings"
+
+	"golang.org/x/text/currency"
+)
+
+// Таблица ISO 4217 берётся из golang.org/x/text/currency: она сгенерирована
+// из CLDR и компилируется в бинарник, сеть и файлы не нужны.
+
+// IsCurrency — code является действующим кодом валюты ISO 4217.
+// Регистр важен: "rub" — не код, хотя x/text его и принял бы.
+func IsCurrency(code string) bool {
+	if len(code) != 3 || code != strings.ToUpper(code) {
+		return false
+	}
+	_, err := currency.ParseISO(code)
+	return err == nil
+}
+
+// minorUnits — валюты, у которых по ISO 4217 не два знака после запятой.
+// CLDR (и x/text) для части валют даёт другое число — для показа, а не для
+// расчётов, поэтому таблица своя.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// MinorUnits — число знаков дробной части валюты по ISO 4217 (RUB — 2,
+// JPY — 0, KWD — 3). Для неизвестного кода ok=false.
+func MinorUnits(code string) (digits int, ok bool) {
+	if !IsCurrency(code) {
+		return 0, false
+	}
+	if d, ok := minorUnits[code]; ok {
+		return d, true
+	}
+	return 2, true
+}
@@ -0,0 +1,22 @@
+package refdata
+
+import (
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// IsLocale — tag является корректным тегом BCP 47 с известным CLDR языком
+// ("en", "ru-RU", "zh-Hant-TW"). Подчёркивание ("en_US") x/text молча
+// исправляет, но в BCP 47 его нет, поэтому такой тег отвергается.
+func IsLocale(tag string) bool {
+	if tag == "" || strings.Contains(tag, "_") {
+		return false
+	}
+	t, err := language.Parse(tag)
+	if err != nil {
+		return false
+	}
+	base, conf := t.Base()
+	return conf == language.Exact && base.String() != "und"
+}
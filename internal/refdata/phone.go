@@ -0,0 +1,116 @@
+package refdata
+
+import (
+	"errors"
+	"strings"
+)
+
+// callingCodes — коды стран ITU-T E.164 → ISO 3166-1 alpha-2. Для общих
+// кодов (1 — NANP, 7 — Россия и Казахстан) указана основная страна, уточнение
+// — в phoneCountry.
+var callingCodes = map[string]string{
+	"1": "US", "7": "RU",
+	"20": "EG", "27": "ZA", "30": "GR", "31": "NL", "32": "BE", "33": "FR",
+	"34": "ES", "36": "HU", "39": "IT", "40": "RO", "41": "CH", "43": "AT",
+	"44": "GB", "45": "DK", "46": "SE", "47": "NO", "48": "PL", "49": "DE",
+	"51": "PE", "52": "MX", "54": "AR", "55": "BR", "56": "CL", "57": "CO",
+	"60": "MY", "61": "AU", "62": "ID", "63": "PH", "64": "NZ", "65": "SG",
+	"66": "TH", "81": "JP", "82": "KR", "84": "VN", "86": "CN", "90": "TR",
+	"91": "IN", "92": "PK", "98": "IR",
+	"351": "PT", "353": "IE", "358": "FI", "359": "BG", "370": "LT", "371": "LV",
+	"372": "EE", "373": "MD", "374": "AM", "375": "BY", "380": "UA", "381": "RS",
+	"385": "HR", "420": "CZ", "421": "SK", "852": "HK", "886": "TW",
+	"971": "AE", "972": "IL", "992": "TJ", "993": "TM", "994": "AZ",
+	"995": "GE", "996": "KG", "998": "UZ",
+}
+
+// countryCallingCode — обратная таблица для номеров без кода страны.
+var countryCallingCode = func() map[string]string {
+	m := make(map[string]string, len(callingCodes)+2)
+	for code, country := range callingCodes {
+		m[country] = code
+	}
+	m["CA"] = "1"
+	m["KZ"] = "7"
+	return m
+}()
+
+var (
+	ErrPhoneChars   = errors.New("contains characters other than digits, spaces, dashes, dots and parentheses")
+	ErrPhoneLength  = errors.New("must have 8..15 digits including the country code")
+	ErrPhoneCountry = errors.New("unknown country calling code")
+)
+
+// NormalizePhone приводит номер к E.164 ("+79991234567") и возвращает страну
+// по коду. Принимаются "+7 (999) 123-45-67", "0079991234567" и номера без
+// кода страны — тогда он берётся из defaultCountry ("8 999 123-45-67" для RU,
+// национальный префикс 8/0/1 отбрасывается).
+func NormalizePhone(raw, defaultCountry string) (e164, country string, err error) {
+	s := strings.TrimSpace(raw)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		s, international = s[1:], true
+	case strings.HasPrefix(s, "00"):
+		s, international = s[2:], true
+	}
+
+	digits := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digits = append(digits, c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", "", ErrPhoneChars
+		}
+	}
+
+	num := string(digits)
+	if !international {
+		code, ok := countryCallingCode[defaultCountry]
+		if !ok {
+			return "", "", ErrPhoneCountry
+		}
+		num = code + trimTrunkPrefix(num, code)
+	}
+	if len(num) < 8 || len(num) > 15 {
+		return "", "", ErrPhoneLength
+	}
+	country = phoneCountry(num)
+	if country == "" {
+		return "", "", ErrPhoneCountry
+	}
+	return "+" + num, country, nil
+}
+
+// trimTrunkPrefix убирает национальный префикс выхода на междугороднюю
+// связь: 8 в России и Казахстане, 1 в NANP (только у 11-значных), 0 почти везде.
+func trimTrunkPrefix(num, code string) string {
+	switch {
+	case code == "7" && len(num) == 11 && num[0] == '8':
+		return num[1:]
+	case code == "1" && len(num) == 11 && num[0] == '1':
+		return num[1:]
+	case code != "1" && code != "7" && strings.HasPrefix(num, "0"):
+		return num[1:]
+	}
+	return num
+}
+
+// phoneCountry — страна по самому длинному совпавшему коду.
+func phoneCountry(num string) string {
+	for n := 3; n >= 1; n-- {
+		if len(num) <= n {
+			continue
+		}
+		if country, ok := callingCodes[num[:n]]; ok {
+			// +7 6xx / +7 7xx — Казахстан
+			if country == "RU" && (num[1] == '6' || num[1] == '7') {
+				return "KZ"
+			}
+			return country
+		}
+	}
+	return ""
+}
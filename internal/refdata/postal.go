@@ -0,0 +1,84 @@
+package refdata
+
+import (
+	"regexp"
+	"strings"
+)
+
+// postalFormats — форматы почтовых индексов по ISO 3166-1 alpha-2 (по данным
+// Universal Postal Union). Сравнение идёт с индексом в верхнем регистре.
+var postalFormats = map[string]*regexp.Regexp{
+	"RU": regexp.MustCompile(`^\d{6}$`),
+	"BY": regexp.MustCompile(`^\d{6}$`),
+	"KZ": regexp.MustCompile(`^(\d{6}|[A-Z]\d{2}[A-Z]\d[A-Z]\d)$`),
+	"UZ": regexp.MustCompile(`^\d{6}$`),
+	"KG": regexp.MustCompile(`^\d{6}$`),
+	"TJ": regexp.MustCompile(`^\d{6}$`),
+	"TM": regexp.MustCompile(`^\d{6}$`),
+	"AM": regexp.MustCompile(`^\d{4}$`),
+	"GE": regexp.MustCompile(`^\d{4}$`),
+	"AZ": regexp.MustCompile(`^(AZ)? ?\d{4}$`),
+	"MD": regexp.MustCompile(`^(MD-?)?\d{4}$`),
+	"UA": regexp.MustCompile(`^\d{5}$`),
+	"LT": regexp.MustCompile(`^(LT-?)?\d{5}$`),
+	"LV": regexp.MustCompile(`^(LV-?)?\d{4}$`),
+	"EE": regexp.MustCompile(`^\d{5}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"CZ": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SK": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"FI": regexp.MustCompile(`^\d{5}$`),
+	"TR": regexp.MustCompile(`^\d{5}$`),
+	"RS": regexp.MustCompile(`^\d{5}$`),
+	"HR": regexp.MustCompile(`^\d{5}$`),
+	"GR": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"SE": regexp.MustCompile(`^\d{3} ?\d{2}$`),
+	"NL": regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`),
+	"BE": regexp.MustCompile(`^\d{4}$`),
+	"CH": regexp.MustCompile(`^\d{4}$`),
+	"AT": regexp.MustCompile(`^\d{4}$`),
+	"DK": regexp.MustCompile(`^\d{4}$`),
+	"NO": regexp.MustCompile(`^\d{4}$`),
+	"HU": regexp.MustCompile(`^\d{4}$`),
+	"BG": regexp.MustCompile(`^\d{4}$`),
+	"PT": regexp.MustCompile(`^\d{4}-\d{3}$`),
+	"IE": regexp.MustCompile(`^[A-Z]\d[\dW] ?[A-Z\d]{4}$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"CA": regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`),
+	"MX": regexp.MustCompile(`^\d{5}$`),
+	"BR": regexp.MustCompile(`^\d{5}-?\d{3}$`),
+	"AR": regexp.MustCompile(`^([A-Z]\d{4}[A-Z]{3}|\d{4})$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+	"JP": regexp.MustCompile(`^\d{3}-?\d{4}$`),
+	"KR": regexp.MustCompile(`^\d{5}$`),
+	"AU": regexp.MustCompile(`^\d{4}$`),
+	"NZ": regexp.MustCompile(`^\d{4}$`),
+	"IL": regexp.MustCompile(`^\d{5}(\d{2})?$`),
+	"ZA": regexp.MustCompile(`^\d{4}$`),
+	"EG": regexp.MustCompile(`^\d{5}$`),
+}
+
+// genericPostal — для стран без своего формата: 3..10 букв и цифр,
+// внутри допустимы пробел и дефис.
+var genericPostal = regexp.MustCompile(`^[A-Z0-9][A-Z0-9 -]{1,8}[A-Z0-9]$`)
+
+// IsPostalCode проверяет индекс по формату страны country (ISO 3166-1 alpha-2).
+func IsPostalCode(country, zip string) bool {
+	zip = strings.ToUpper(strings.TrimSpace(zip))
+	if re, ok := postalFormats[country]; ok {
+		return re.MatchString(zip)
+	}
+	return zip != "" && genericPostal.MatchString(zip)
+}
+
+// IsCountry — для страны известен код телефона или формат индекса.
+func IsCountry(country string) bool {
+	_, phone := countryCallingCode[country]
+	_, zip := postalFormats[country]
+	return phone || zip
+}
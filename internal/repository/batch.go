@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"L0/internal/logging"
+	"L0/internal/model"
+	"L0/internal/retry"
+	"L0/internal/tracing"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// staged — заказ пачки, прошедший разбор конфликтов и идущий в staging-таблицы.
+type staged struct {
+	order   model.Order
+	hash    string
+	outcome writeOutcome // inserted или overwritten
+}
+
+// SaveBatch записывает пачку заказов одной транзакцией: строки заливаются
+// COPY во временные таблицы и сливаются в основные несколькими запросами.
+// Итог по каждому заказу тот же, что у SaveOrder. Любая ошибка откатывает
+// всю пачку — разбирать её по одному заказу должен вызывающий. Позиции из
+// WithPositions идут по одной на заказ; заказы с уже применённым оффсетом
+// пропускаются.
+func (r *Repository) SaveBatch(ctx context.Context, orders []model.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "repository.SaveBatch", attribute.Int("batch.size", len(orders)))
+	defer func() { tracing.End(span, err) }()
+
+	// заказы дополняем на копии: при откате вызывающий пишет исходные по одному
+	orders = slices.Clone(orders)
+	ps := r.positions(ctx)
+	if len(ps) > 0 && len(ps) != len(orders) {
+		return fmt.Errorf("batch: %d positions for %d orders", len(ps), len(orders))
+	}
+	hashes := make(map[string]string, len(orders))
+	for i := range orders {
+		o := &orders[i]
+		if _, dup := hashes[o.OrderUID]; dup {
+			return fmt.Errorf("batch: duplicate order_uid %s", o.OrderUID)
+		}
+		o.Delivery.OrderID = o.OrderUID
+		o.Payment.OrderID = o.OrderUID
+		for j := range o.Items {
+			o.Items[j].OrderID = o.OrderUID
+		}
+		hash, err := o.Fingerprint()
+		if err != nil {
+			return retry.Permanent(fmt.Errorf("fingerprint %s: %w", o.OrderUID, err))
+		}
+		// как в SaveOrder: статус по умолчанию — после отпечатка
+		if o.Status == "" {
+			o.Status = model.StatusCreated
+		}
+		hashes[o.OrderUID] = hash
+	}
+
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if len(ps) > 0 {
+		next, err := r.lockOffsets(ctx, tx, ps)
+		if err != nil {
+			return err
+		}
+		fresh := orders[:0]
+		for i, o := range orders {
+			if ps[i].Offset >= next[ps[i].key()] {
+				fresh = append(fresh, o)
+			}
+		}
+		if skipped := len(orders) - len(fresh); skipped > 0 {
+			logging.FromContext(ctx).Info("часть пачки уже применена: оффсеты записаны в БД", "skipped", skipped)
+		}
+		orders = fresh
+	}
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+	}
+
+	// уже записанные заказы пачки блокируем и сверяем отпечатки
+	rows, err := tx.Query(ctx,
+		`SELECT order_uid, payload_hash FROM orders WHERE order_uid = ANY($1) FOR UPDATE`, uids)
+	if err != nil {
+		return fmt.Errorf("orders lock: %w", err)
+	}
+	stored := make(map[string]*string, len(orders))
+	for rows.Next() {
+		var uid string
+		var hash *string
+		if err := rows.Scan(&uid, &hash); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan orders lock: %w", err)
+		}
+		stored[uid] = hash
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows orders lock: %w", err)
+	}
+
+	outcomes := make(map[string]writeOutcome, len(orders))
+	batch := make([]staged, 0, len(orders))
+	inserts := 0
+	for _, o := range orders {
+		outcome := outcomeInserted
+		if h, ok := stored[o.OrderUID]; ok {
+			outcome, err = r.resolveConflict(logging.With(ctx, logging.KeyOrderUID, o.OrderUID), o.OrderUID, h, hashes[o.OrderUID])
+			if err != nil {
+				return err
+			}
+		}
+		outcomes[o.OrderUID] = outcome
+		switch outcome {
+		case outcomeInserted:
+			inserts++
+		case outcomeOverwritten:
+		default:
+			continue
+		}
+		batch = append(batch, staged{order: o, hash: hashes[o.OrderUID], outcome: outcome})
+	}
+
+	if len(batch) > 0 {
+		if err := r.mergeBatch(ctx, tx, batch, inserts); err != nil {
+			return err
+		}
+		events := make([]model.OrderEvent, 0, len(batch))
+		for _, s := range batch {
+			if e, ok := orderEvent(s.order, s.hash, s.outcome); ok {
+				events = append(events, e)
+			}
+		}
+		if err := r.enqueueEvents(ctx, tx, events); err != nil {
+			return err
+		}
+	}
+
+	if err := r.storeOffsets(ctx, tx, ps); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	for _, o := range orders {
+		r.written(o.OrderUID, func() {
+			if r.NotFound != nil {
+				r.NotFound.Delete(o.OrderUID)
+			}
+			// правила кеша те же, что в SaveOrder
+			if outcomes[o.OrderUID] == outcomeInserted {
+				r.Cash.Set(o.OrderUID, o)
+			} else {
+				r.Cash.Delete(o.OrderUID)
+			}
+		})
+	}
+	return nil
+}
+
+// mergeBatch заливает заказы в staging-таблицы и переносит их в основные.
+func (r *Repository) mergeBatch(ctx context.Context, tx pgx.Tx, batch []staged, inserts int) error {
+	// staging-таблицы повторяют колонки основных и живут до конца транзакции
+	for _, q := range []string{
+		`CREATE TEMP TABLE stage_orders ON COMMIT DROP AS
+			SELECT order_uid, track_number, entry, locale, internal_signature, customer_id,
+			       delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash, status,
+			       false AS overwrite
+			FROM orders WITH NO DATA`,
+		`CREATE TEMP TABLE stage_deliveries ON COMMIT DROP AS
+			SELECT order_id, name, phone, zip, city, address, region, email
+			FROM deliveries WITH NO DATA`,
+		`CREATE TEMP TABLE stage_payments ON COMMIT DROP AS
+			SELECT order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+			       bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
+			FROM payments WITH NO DATA`,
+		`CREATE TEMP TABLE stage_items ON COMMIT DROP AS
+			SELECT order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+			       total_price_minor, nm_id, brand, status
+			FROM order_items WITH NO DATA`,
+	} {
+		if _, err := tx.Exec(ctx, q); err != nil {
+			return fmt.Errorf("staging create: %w", err)
+		}
+	}
+
+	var ords, dels, pays, items [][]any
+	for _, s := range batch {
+		o := s.order
+		ords = append(ords, []any{
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+			o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, s.hash, string(o.Status),
+			s.outcome == outcomeOverwritten,
+		})
+		dels = append(dels, []any{
+			o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
+			o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+		})
+		pays = append(pays, []any{
+			o.OrderUID, o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
+			o.Payment.Amount.Minor, o.Payment.PaymentDT, o.Payment.Bank,
+			o.Payment.DeliveryCost.Minor, o.Payment.GoodsTotal.Minor, o.Payment.CustomFee.Minor,
+		})
+		for _, it := range o.Items {
+			items = append(items, []any{
+				o.OrderUID, it.ChrtID, it.TrackNumber, it.Price.Minor, it.RID, it.Name, it.Sale, it.Size,
+				it.TotalPrice.Minor, it.NmID, it.Brand, int(it.Status),
+			})
+		}
+	}
+
+	for _, c := range []struct {
+		table string
+		cols  []string
+		rows  [][]any
+	}{
+		{"stage_orders", []string{
+			"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+			"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "payload_hash", "status",
+			"overwrite",
+		}, ords},
+		{"stage_deliveries", []string{
+			"order_id", "name", "phone", "zip", "city", "address", "region", "email",
+		}, dels},
+		{"stage_payments", []string{
+			"order_id", "transaction", "request_id", "currency", "provider", "amount_minor", "payment_dt",
+			"bank", "delivery_cost_minor", "goods_total_minor", "custom_fee_minor",
+		}, pays},
+		{"stage_items", []string{
+			"order_id", "chrt_id", "track_number", "price_minor", "rid", "name", "sale", "size",
+			"total_price_minor", "nm_id", "brand", "status",
+		}, items},
+	} {
+		if len(c.rows) == 0 {
+			continue
+		}
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{c.table}, c.cols, pgx.CopyFromRows(c.rows)); err != nil {
+			return fmt.Errorf("copy %s: %w", c.table, err)
+		}
+	}
+
+	// orders: новые вставляются вместе с началом истории статусов. Если заказ
+	// успел появиться после блокировки (параллельная запись), строк вставится
+	// меньше — пачку откатываем, а не пишем дочерние таблицы мимо политики.
+	tag, err := tx.Exec(ctx, `
+		WITH ins AS (
+			INSERT INTO orders (
+				order_uid, track_number, entry, locale, internal_signature,
+				customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash, status
+			)
+			SELECT order_uid, track_number, entry, locale, internal_signature,
+			       customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash, status
+			FROM stage_orders WHERE NOT overwrite
+			ON CONFLICT (order_uid) DO NOTHING
+			RETURNING order_uid, status, date_created
+		)
+		INSERT INTO order_status_history (order_id, to_status, changed_at)
+		SELECT order_uid, status, date_created FROM ins
+	`)
+	if err != nil {
+		return fmt.Errorf("orders insert: %w", err)
+	}
+	if int(tag.RowsAffected()) != inserts {
+		return fmt.Errorf("orders insert: %d of %d inserted, concurrent write", tag.RowsAffected(), inserts)
+	}
+
+	// статус не перезаписываем: им управляют события смены статуса
+	if _, err := tx.Exec(ctx, `
+		UPDATE orders o SET
+			track_number = s.track_number, entry = s.entry, locale = s.locale,
+			internal_signature = s.internal_signature, customer_id = s.customer_id,
+			delivery_service = s.delivery_service, shardkey = s.shardkey, sm_id = s.sm_id,
+			date_created = s.date_created, oof_shard = s.oof_shard, payload_hash = s.payload_hash
+		FROM stage_orders s
+		WHERE o.order_uid = s.order_uid AND s.overwrite
+	`); err != nil {
+		return fmt.Errorf("orders update: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO deliveries (order_id, name, phone, zip, city, address, region, email)
+		SELECT order_id, name, phone, zip, city, address, region, email FROM stage_deliveries
+		ON CONFLICT (order_id) DO UPDATE SET
+			name = excluded.name, phone = excluded.phone, zip = excluded.zip, city = excluded.city,
+			address = excluded.address, region = excluded.region, email = excluded.email
+	`); err != nil {
+		return fmt.Errorf("deliveries upsert: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM payments p USING stage_payments s
+		WHERE p.order_id = s.order_id AND p.transaction <> s.transaction
+	`); err != nil {
+		return fmt.Errorf("payments cleanup: %w", err)
+	}
+	tag, err = tx.Exec(ctx, `
+		INSERT INTO payments (
+			order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+			bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
+		)
+		SELECT order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+		       bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
+		FROM stage_payments
+		ON CONFLICT (transaction) DO UPDATE SET
+			request_id = excluded.request_id, currency = excluded.currency, provider = excluded.provider,
+			amount_minor = excluded.amount_minor, payment_dt = excluded.payment_dt, bank = excluded.bank,
+			delivery_cost_minor = excluded.delivery_cost_minor, goods_total_minor = excluded.goods_total_minor,
+			custom_fee_minor = excluded.custom_fee_minor
+		WHERE payments.order_id = excluded.order_id
+	`)
+	if err != nil {
+		return fmt.Errorf("payments upsert: %w", err)
+	}
+	if int(tag.RowsAffected()) != len(batch) {
+		// какой именно заказ чужой, покажет поштучная запись
+		return fmt.Errorf("payments upsert: transaction belongs to another order")
+	}
+
+	// статус существующей позиции не трогаем — как и у заказа
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO order_items (
+			order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+			total_price_minor, nm_id, brand, status
+		)
+		SELECT order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+		       total_price_minor, nm_id, brand, status
+		FROM stage_items
+		ON CONFLICT (order_id, chrt_id, rid) DO UPDATE SET
+			track_number = excluded.track_number, price_minor = excluded.price_minor, name = excluded.name,
+			sale = excluded.sale, size = excluded.size, total_price_minor = excluded.total_price_minor,
+			nm_id = excluded.nm_id, brand = excluded.brand
+	`); err != nil {
+		return fmt.Errorf("items upsert: %w", err)
+	}
+	// товары, которых нет в новой версии заказа, удаляем
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM order_items i USING stage_orders s
+		WHERE i.order_id = s.order_uid
+		  AND NOT EXISTS (
+			SELECT 1 FROM stage_items si
+			WHERE si.order_id = i.order_id AND si.chrt_id = i.chrt_id AND si.rid = i.rid
+		  )
+	`); err != nil {
+		return fmt.Errorf("items cleanup: %w", err)
+	}
+	return nil
+}
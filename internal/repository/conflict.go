@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ConflictPolicy — что делать, если order_uid уже записан, а пришедший
+// заказ отличается от сохранённого (повторная доставка того же содержимого
+// всегда просто подтверждается).
+type ConflictPolicy string
+
+const (
+	PolicyOverwrite ConflictPolicy = "overwrite"  // перезаписать сохранённый заказ новым
+	PolicyKeepFirst ConflictPolicy = "keep-first" // оставить первый, новое содержимое отбросить
+	PolicyReject    ConflictPolicy = "reject"     // вернуть ErrPayloadConflict (→ DLQ)
+)
+
+var ErrPayloadConflict = errors.New("order already stored with a different payload")
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case PolicyOverwrite, PolicyKeepFirst, PolicyReject:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want overwrite, keep-first or reject)", s)
+}
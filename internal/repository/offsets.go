@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Position — место сообщения в Kafka. В режиме хранения оффсетов в Postgres
+// консьюмер кладёт позиции в контекст записи (WithPositions), и запись
+// фиксирует их той же транзакцией, что и данные сообщения.
+type Position struct {
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+type positionsKey struct{}
+
+// WithPositions кладёт в контекст позиции сообщений, которые пишет вызов:
+// для SaveOrder и UpdateStatus — одну, для SaveBatch — по одной на заказ
+// в том же порядке.
+func WithPositions(ctx context.Context, ps ...Position) context.Context {
+	return context.WithValue(ctx, positionsKey{}, ps)
+}
+
+// positions — позиции из контекста; без r.OffsetGroup оффсеты в БД не пишутся.
+func (r *Repository) positions(ctx context.Context) []Position {
+	if r.OffsetGroup == "" {
+		return nil
+	}
+	ps, _ := ctx.Value(positionsKey{}).([]Position)
+	return ps
+}
+
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+func (p Position) key() partitionKey { return partitionKey{p.Topic, p.Partition} }
+
+// lockOffsets блокирует строки kafka_offsets партиций из ps (создавая
+// недостающие) и возвращает их next_offset: сообщение с меньшим оффсетом
+// уже применено. Партиции блокируются по порядку, чтобы параллельные
+// транзакции не взаимоблокировались.
+func (r *Repository) lockOffsets(ctx context.Context, tx pgx.Tx, ps []Position) (map[partitionKey]int64, error) {
+	keys := make([]partitionKey, 0, len(ps))
+	for _, p := range ps {
+		if !slices.Contains(keys, p.key()) {
+			keys = append(keys, p.key())
+		}
+	}
+	slices.SortFunc(keys, func(a, b partitionKey) int {
+		return cmp.Or(cmp.Compare(a.topic, b.topic), cmp.Compare(a.partition, b.partition))
+	})
+
+	next := make(map[partitionKey]int64, len(keys))
+	for _, k := range keys {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO kafka_offsets (group_id, topic, partition, next_offset) VALUES ($1, $2, $3, 0)
+			ON CONFLICT (group_id, topic, partition) DO NOTHING
+		`, r.OffsetGroup, k.topic, k.partition); err != nil {
+			return nil, fmt.Errorf("offsets insert: %w", err)
+		}
+		var off int64
+		if err := tx.QueryRow(ctx, `
+			SELECT next_offset FROM kafka_offsets
+			WHERE group_id = $1 AND topic = $2 AND partition = $3
+			FOR UPDATE
+		`, r.OffsetGroup, k.topic, k.partition).Scan(&off); err != nil {
+			return nil, fmt.Errorf("offsets lock: %w", err)
+		}
+		next[k] = off
+	}
+	return next, nil
+}
+
+// claimPositions блокирует позиции сообщения; true — оно уже применено
+// (оффсет записан раньше), и писать нечего.
+func (r *Repository) claimPositions(ctx context.Context, tx pgx.Tx, ps []Position) (bool, error) {
+	if len(ps) == 0 {
+		return false, nil
+	}
+	next, err := r.lockOffsets(ctx, tx, ps)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range ps {
+		if p.Offset >= next[p.key()] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// storeOffsets сдвигает next_offset партиций за последние оффсеты ps.
+// Строки должны быть заблокированы lockOffsets. Всё, что ниже нового
+// next_offset, считается применённым, поэтому консьюмер пишет сообщения
+// партиции строго по порядку и не идёт дальше неприменённого (см.
+// consumer.deadLetter).
+func (r *Repository) storeOffsets(ctx context.Context, tx pgx.Tx, ps []Position) error {
+	last := make(map[partitionKey]int64, len(ps))
+	for _, p := range ps {
+		last[p.key()] = max(last[p.key()], p.Offset+1)
+	}
+	for k, next := range last {
+		if _, err := tx.Exec(ctx, `
+			UPDATE kafka_offsets SET next_offset = GREATEST(next_offset, $4), updated_at = now()
+			WHERE group_id = $1 AND topic = $2 AND partition = $3
+		`, r.OffsetGroup, k.topic, k.partition, next); err != nil {
+			return fmt.Errorf("offsets update: %w", err)
+		}
+	}
+	return nil
+}
+
+// commitPositions фиксирует позиции, когда сама запись ничего не изменила.
+func (r *Repository) commitPositions(ctx context.Context, tx pgx.Tx, ps []Position) error {
+	if len(ps) == 0 {
+		return nil
+	}
+	if err := r.storeOffsets(ctx, tx, ps); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// StoreOffsets записывает позиции сообщений, которые не дошли до записи
+// данных (ушли в DLQ), чтобы после рестарта они не читались снова.
+func (r *Repository) StoreOffsets(ctx context.Context, ps ...Position) error {
+	if r.OffsetGroup == "" || len(ps) == 0 {
+		return nil
+	}
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := r.lockOffsets(ctx, tx, ps); err != nil {
+		return err
+	}
+	return r.commitPositions(ctx, tx, ps)
+}
+
+// LoadOffsets — сохранённые позиции чтения топика: партиция → первый ещё
+// не применённый оффсет.
+func (r *Repository) LoadOffsets(ctx context.Context, topic string) (map[int]int64, error) {
+	rows, err := r.Conn.Query(ctx, `
+		SELECT partition, next_offset FROM kafka_offsets WHERE group_id = $1 AND topic = $2
+	`, r.OffsetGroup, topic)
+	if err != nil {
+		return nil, fmt.Errorf("select offsets: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[int]int64)
+	for rows.Next() {
+		var partition int
+		var next int64
+		if err := rows.Scan(&partition, &next); err != nil {
+			return nil, fmt.Errorf("scan offsets: %w", err)
+		}
+		out[partition] = next
+	}
+	return out, rows.Err()
+}
+
+// ResetOffsets перезаписывает позиции чтения партиций топика (перемотка
+// группы администратором). В отличие от storeOffsets, позиция может уйти
+// и назад: сообщения с неё будут применены заново.
+func (r *Repository) ResetOffsets(ctx context.Context, topic string, next map[int]int64) error {
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for partition, off := range next {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO kafka_offsets (group_id, topic, partition, next_offset) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (group_id, topic, partition)
+			DO UPDATE SET next_offset = EXCLUDED.next_offset, updated_at = now()
+		`, r.OffsetGroup, topic, partition, off); err != nil {
+			return fmt.Errorf("offsets reset: %w", err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"L0/internal/model"
+	"L0/internal/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// orderEvent — событие о записи заказа; для duplicate и kept событий нет.
+func orderEvent(o model.Order, hash string, outcome writeOutcome) (model.OrderEvent, bool) {
+	e := model.OrderEvent{
+		OrderUID:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		CustomerID:  o.CustomerID,
+		PayloadHash: hash,
+		OccurredAt:  time.Now().UTC(),
+	}
+	switch outcome {
+	case outcomeInserted:
+		e.Type = model.EventOrderStored
+	case outcomeOverwritten:
+		e.Type = model.EventOrderUpdated
+	default:
+		return e, false
+	}
+	return e, true
+}
+
+// enqueueEvents пишет события в outbox той же транзакцией, что и заказы;
+// публикует их outbox.Relay. Контекст трассировки сохраняется, чтобы
+// публикация продолжила трассу записи.
+func (r *Repository) enqueueEvents(ctx context.Context, tx pgx.Tx, events []model.OrderEvent) error {
+	if !r.Outbox || len(events) == 0 {
+		return nil
+	}
+	headers, err := json.Marshal(tracing.InjectMap(ctx))
+	if err != nil {
+		return fmt.Errorf("outbox headers: %w", err)
+	}
+
+	types := make([]string, 0, len(events))
+	keys := make([]string, 0, len(events))
+	payloads := make([]string, 0, len(events))
+	for _, e := range events {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("outbox payload: %w", err)
+		}
+		types = append(types, e.Type)
+		keys = append(keys, e.OrderUID)
+		payloads = append(payloads, string(data))
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO outbox (event_type, aggregate_id, payload, headers)
+		SELECT t, k, p::jsonb, $4::jsonb
+		FROM unnest($1::text[], $2::text[], $3::text[]) WITH ORDINALITY AS e(t, k, p, n)
+		ORDER BY n
+	`, types, keys, payloads, string(headers)); err != nil {
+		return fmt.Errorf("outbox insert: %w", err)
+	}
+	return nil
+}
@@ -1,72 +1,189 @@
 package repository
 
 import (
+	"L0/internal/cache"
+	"L0/internal/logging"
 	"L0/internal/model"
+	"L0/internal/tracing"
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"sync"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 )
 
+// dbLoadTimeout ограничивает общую загрузку заказа из БД: она не привязана
+// к контексту первого запроса, чтобы его отмена не роняла остальных ждущих.
+const dbLoadTimeout = 5 * time.Second
+
 var ErrNotFound = errors.New("order not found")
 
+// ErrAmbiguousItem — rid события статуса совпал у нескольких позиций заказа,
+// а chrt_id, который их различает, не указан.
+var ErrAmbiguousItem = errors.New("item is ambiguous")
+
 // Интерфейсы — пригодятся для тестов/моков и хэндлеров.
 type OrderReader interface {
 	GetOrderByID(ctx context.Context, id string) (model.Order, bool, error)
 }
 
+// Единый путь записи: консьюмер Kafka, HTTP и тесты пишут заказы и смены
+// статусов через него.
 type OrderWriter interface {
-	// Опционально, если будешь писать в БД через репозиторий
 	SaveOrder(ctx context.Context, o model.Order) error
+	SaveBatch(ctx context.Context, orders []model.Order) error
+	UpdateStatus(ctx context.Context, u model.StatusUpdate) error
+	StoreOffsets(ctx context.Context, ps ...Position) error
 }
 
 // Базовая реализация.
 type Repository struct {
-	Conn *pgxpool.Pool
+	Conn   *pgxpool.Pool
+	Policy ConflictPolicy // как разрешать расхождение с уже сохранённым заказом
+
+	// consumer group, чьи оффсеты пишутся в kafka_offsets вместе с данными
+	// (пусто — оффсеты хранит Kafka, позиции из WithPositions не пишутся)
+	OffsetGroup string
+
+	Outbox bool // события о записи заказов пишутся в outbox для outbox.Relay
+
+	Cash     cache.Cache[string, model.Order] // order_uid → Order
+	NotFound cache.Cache[string, struct{}]    // недавно не найденные id (nil — не кешируем)
 
-	mu   sync.RWMutex
-	Cash map[string]model.Order // map[order_uid]Order
+	loads singleflight.Group // одна загрузка из БД на id, сколько бы запросов ни ждало
+
+	loadMu   sync.Mutex
+	inflight map[string]*inflight // id → идущие загрузки из БД (см. startLoad)
+}
+
+// inflight — заказ, который сейчас читается из БД.
+type inflight struct {
+	gen   uint64 // растёт с каждой записью заказа
+	loads int    // сколько загрузок идёт
 }
 
-func New(conn *pgxpool.Pool) Repository {
-	return Repository{
-		Conn: conn,
-		Cash: make(map[string]model.Order),
+var _ OrderWriter = (*Repository)(nil)
+
+func New(conn *pgxpool.Pool, c cache.Cache[string, model.Order]) *Repository {
+	return &Repository{
+		Conn:   conn,
+		Policy: PolicyOverwrite,
+		Cash:   c,
 	}
 }
 
 func (r *Repository) GetOrderById(ctx context.Context, id string) (model.Order, bool, error) {
-	r.mu.RLock()
-	if o, ok := r.Cash[id]; ok {
-		r.mu.RUnlock()
-		log.Printf("Кэше есть заказ с id = %s\n", id)
-		return o, true, nil
+	_, span := tracing.Start(ctx, "cache.get", attribute.String("order.uid", id))
+	o, hit := r.Cash.Get(id)
+	negative := false
+	if !hit && r.NotFound != nil {
+		_, negative = r.NotFound.Get(id)
 	}
-	r.mu.RUnlock()
-
-	log.Printf("Кэше нет заказа с id = %s, иду в бд\n", id)
+	span.SetAttributes(attribute.Bool("cache.hit", hit), attribute.Bool("cache.negative_hit", negative))
+	span.End()
 
-	o, err := r.TakeOrderFromDB(ctx, id)
-	if err != nil {
-		return model.Order{}, false, err
+	if hit {
+		logging.FromContext(ctx).Debug("заказ найден в кеше", logging.KeyOrderUID, id)
+		return o, true, nil
 	}
-	if o == nil {
+	if negative {
 		return model.Order{}, false, ErrNotFound
 	}
+	logging.FromContext(ctx).Debug("заказа нет в кеше, читаю из БД", logging.KeyOrderUID, id)
 
-	log.Printf("в БД есть заказ с id = %s положил его в кэш\n", id)
+	// конкурентные промахи по одному id схлопываются в одну загрузку
+	ch := r.loads.DoChan(id, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dbLoadTimeout)
+		defer cancel()
 
-	r.mu.Lock()
-	r.Cash[id] = *o
-	r.mu.Unlock()
+		gen := r.startLoad(id)
+		o, err := r.TakeOrderFromDB(loadCtx, id)
+		if err != nil {
+			r.finishLoad(id, gen, nil)
+			return nil, err
+		}
+		if o == nil {
+			r.finishLoad(id, gen, func() {
+				if r.NotFound != nil {
+					r.NotFound.Set(id, struct{}{})
+				}
+			})
+			return nil, ErrNotFound
+		}
 
-	return *o, true, nil
+		if r.finishLoad(id, gen, func() { r.Cash.Set(id, *o) }) {
+			logging.FromContext(ctx).Debug("заказ загружен из БД в кеш", logging.KeyOrderUID, id)
+		}
+		return *o, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return model.Order{}, false, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return model.Order{}, false, res.Err
+		}
+		return res.Val.(model.Order), true, nil
+	}
+}
+
+// startLoad отмечает начало загрузки id из БД и возвращает версию заказа,
+// с которой загрузка сверится в finishLoad.
+func (r *Repository) startLoad(id string) uint64 {
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	if r.inflight == nil {
+		r.inflight = make(map[string]*inflight)
+	}
+	f := r.inflight[id]
+	if f == nil {
+		f = &inflight{}
+		r.inflight[id] = f
+	}
+	f.loads++
+	return f.gen
+}
+
+// finishLoad кладёт результат загрузки в кеш (fill), только если заказ не
+// записывали, пока она шла: иначе загрузка могла прочитать версию старше
+// той, что writer уже положил в кеш или из него убрал. true — fill вызван.
+func (r *Repository) finishLoad(id string, gen uint64, fill func()) bool {
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	f := r.inflight[id]
+	fresh := f.gen == gen
+	if f.loads--; f.loads == 0 {
+		delete(r.inflight, id)
+	}
+	if fresh && fill != nil {
+		fill()
+	}
+	return fresh && fill != nil
+}
+
+// written обновляет кеш после записи заказа (update) так, что идущие
+// загрузки его уже не перезапишут, а новые запросы не присоединятся к ним.
+func (r *Repository) written(id string, update func()) {
+	r.loadMu.Lock()
+	if f := r.inflight[id]; f != nil {
+		f.gen++
+	}
+	update()
+	r.loadMu.Unlock()
+	r.loads.Forget(id)
 }
-func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.Order, error) {
+
+// TakeOrderFromDB читает заказ со всеми дочерними таблицами; nil, nil — заказа нет.
+func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (_ *model.Order, err error) {
+	ctx, span := tracing.Start(ctx, "repository.TakeOrderFromDB", attribute.String("order.uid", id))
+	defer func() { tracing.End(span, err) }()
+
 	var o model.Order
 
 	tx, err := repo.Conn.BeginTx(ctx, pgx.TxOptions{
@@ -82,16 +199,16 @@ func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.
 	if err := tx.QueryRow(ctx, `
 		SELECT order_uid, track_number, entry, locale, internal_signature,
 		       customer_id, delivery_service, shardkey, sm_id,
-		       date_created, oof_shard
+		       date_created, oof_shard, status
 		FROM orders
 		WHERE order_uid = $1
 	`, id).Scan(
 		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
 		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID,
-		&o.DateCreated, &o.OofShard,
+		&o.DateCreated, &o.OofShard, &o.Status,
 	); err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("order %q not found", id)
+			return nil, nil
 		}
 		return nil, fmt.Errorf("select orders: %w", err)
 	}
@@ -113,15 +230,15 @@ func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.
 
 	//  payment
 	if err := tx.QueryRow(ctx, `
-		SELECT order_id, transaction, request_id, currency, provider, amount, payment_dt,
-		       bank, delivery_cost, goods_total, custom_fee
+		SELECT order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+		       bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
 		FROM payments
 		WHERE order_id = $1
 	`, id).Scan(
 		&o.Payment.OrderID, &o.Payment.Transaction, &o.Payment.RequestID,
-		&o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount,
-		&o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost,
-		&o.Payment.GoodsTotal, &o.Payment.CustomFee,
+		&o.Payment.Currency, &o.Payment.Provider, &o.Payment.Amount.Minor,
+		&o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost.Minor,
+		&o.Payment.GoodsTotal.Minor, &o.Payment.CustomFee.Minor,
 	); err != nil {
 		if err != pgx.ErrNoRows {
 			return nil, fmt.Errorf("select payments: %w", err)
@@ -130,8 +247,8 @@ func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.
 
 	// items (мб не 1)
 	rows, err := tx.Query(ctx, `
-		SELECT order_id, chrt_id, track_number, price, rid, name, sale, size,
-		       total_price, nm_id, brand, status
+		SELECT order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+		       total_price_minor, nm_id, brand, status
 		FROM order_items
 		WHERE order_id = $1
 	`, id)
@@ -143,9 +260,9 @@ func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.
 	for rows.Next() {
 		var it model.Item
 		if err := rows.Scan(
-			&it.OrderID, &it.ChrtID, &it.TrackNumber, &it.Price,
+			&it.OrderID, &it.ChrtID, &it.TrackNumber, &it.Price.Minor,
 			&it.RID, &it.Name, &it.Sale, &it.Size,
-			&it.TotalPrice, &it.NmID, &it.Brand, &it.Status,
+			&it.TotalPrice.Minor, &it.NmID, &it.Brand, &it.Status,
 		); err != nil {
 			return nil, fmt.Errorf("scan order_item: %w", err)
 		}
@@ -154,6 +271,7 @@ func (repo *Repository) TakeOrderFromDB(ctx context.Context, id string) (*model.
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows order_items: %w", err)
 	}
+	o.SyncCurrency()
 
 	// сommit
 	if err := tx.Commit(ctx); err != nil {
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"L0/internal/cache"
+	"L0/internal/model"
+	"testing"
+)
+
+func TestLoadDoesNotOverwriteConcurrentWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  bool // запись заказа между началом и концом загрузки
+		cached bool // результат загрузки попал в кеш
+	}{
+		{name: "no write", write: false, cached: true},
+		{name: "write during load", write: true, cached: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repository{Cash: cache.NewLRU[string, model.Order](cache.Options[model.Order]{})}
+			stale := model.Order{OrderUID: "a", Status: model.StatusCreated}
+			fresh := model.Order{OrderUID: "a", Status: model.StatusDelivered}
+
+			gen := r.startLoad("a")
+			if tt.write {
+				r.written("a", func() { r.Cash.Set("a", fresh) })
+			}
+			filled := r.finishLoad("a", gen, func() { r.Cash.Set("a", stale) })
+
+			if filled != tt.cached {
+				t.Errorf("finishLoad = %v, want %v", filled, tt.cached)
+			}
+			want := stale
+			if tt.write {
+				want = fresh
+			}
+			if got, _ := r.Cash.Get("a"); got.Status != want.Status {
+				t.Errorf("cached status = %q, want %q", got.Status, want.Status)
+			}
+			if len(r.inflight) != 0 {
+				t.Errorf("inflight = %v, want empty", r.inflight)
+			}
+		})
+	}
+}
+
+func TestOverlappingLoadsAreBothStale(t *testing.T) {
+	r := &Repository{Cash: cache.NewLRU[string, model.Order](cache.Options[model.Order]{})}
+
+	first := r.startLoad("a")
+	r.written("a", func() { r.Cash.Delete("a") })
+	second := r.startLoad("a") // начата после записи: её результат свежий
+
+	if r.finishLoad("a", first, func() {}) {
+		t.Error("load started before the write filled the cache")
+	}
+	if !r.finishLoad("a", second, func() {}) {
+		t.Error("load started after the write did not fill the cache")
+	}
+	if len(r.inflight) != 0 {
+		t.Errorf("inflight = %v, want empty", r.inflight)
+	}
+}
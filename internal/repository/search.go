@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"L0/internal/model"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+var ErrBadCursor = errors.New("bad cursor")
+
+// OrderFilter — условия поиска заказов. Пустые поля не фильтруют.
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	Transaction     string    // payments.transaction
+	CreatedFrom     time.Time // date_created >= CreatedFrom
+	CreatedTo       time.Time // date_created < CreatedTo
+	NmID            int64     // хотя бы один товар с таким nm_id
+	Brand           string    // хотя бы один товар этого бренда
+
+	Limit  int    // размер страницы, по умолчанию DefaultSearchLimit
+	Cursor string // NextCursor предыдущей страницы
+}
+
+// OrderPage — страница результатов, от новых заказов к старым.
+type OrderPage struct {
+	Orders     []model.Order `json:"orders"`
+	NextCursor string        `json:"next_cursor,omitempty"` // пусто — страниц больше нет
+}
+
+// cursor — позиция последнего заказа на странице: ключ сортировки
+// (date_created, order_uid), по нему следующая страница продолжается
+// без OFFSET и без пропусков при вставке новых заказов.
+type cursor struct {
+	Created time.Time `json:"t"`
+	UID     string    `json:"id"`
+}
+
+func encodeCursor(o model.Order) string {
+	data, _ := json.Marshal(cursor{Created: o.DateCreated, UID: o.OrderUID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrBadCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.UID == "" {
+		return c, ErrBadCursor
+	}
+	return c, nil
+}
+
+// SearchOrders ищет заказы по фильтру с keyset-пагинацией по
+// (date_created DESC, order_uid DESC). Заказы возвращаются целиком.
+func (r *Repository) SearchOrders(ctx context.Context, f OrderFilter) (OrderPage, error) {
+	limit := f.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	var (
+		conds []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.CustomerID != "" {
+		conds = append(conds, "customer_id = "+arg(f.CustomerID))
+	}
+	if f.TrackNumber != "" {
+		conds = append(conds, "track_number = "+arg(f.TrackNumber))
+	}
+	if f.DeliveryService != "" {
+		conds = append(conds, "delivery_service = "+arg(f.DeliveryService))
+	}
+	if !f.CreatedFrom.IsZero() {
+		conds = append(conds, "date_created >= "+arg(f.CreatedFrom))
+	}
+	if !f.CreatedTo.IsZero() {
+		conds = append(conds, "date_created < "+arg(f.CreatedTo))
+	}
+	if f.Transaction != "" {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM payments p
+			WHERE p.order_id = orders.order_uid AND p.transaction = `+arg(f.Transaction)+`)`)
+	}
+	if f.NmID != 0 {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM order_items i
+			WHERE i.order_id = orders.order_uid AND i.nm_id = `+arg(f.NmID)+`)`)
+	}
+	if f.Brand != "" {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM order_items i
+			WHERE i.order_id = orders.order_uid AND i.brand = `+arg(f.Brand)+`)`)
+	}
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return OrderPage{}, err
+		}
+		conds = append(conds, "(date_created, order_uid) < ("+arg(c.Created)+", "+arg(c.UID)+")")
+	}
+
+	query := selectOrders
+	if len(conds) > 0 {
+		query += "WHERE " + strings.Join(conds, "\n\t  AND ") + "\n"
+	}
+	// берём на одну строку больше, чтобы понять, есть ли следующая страница
+	query += "ORDER BY date_created DESC, order_uid DESC\nLIMIT " + arg(limit+1)
+
+	tx, err := r.Conn.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		return OrderPage{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return OrderPage{}, fmt.Errorf("search orders: %w", err)
+	}
+	orders, err := scanOrders(rows)
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	var page OrderPage
+	if len(orders) > limit {
+		orders = orders[:limit]
+		page.NextCursor = encodeCursor(orders[limit-1])
+	}
+	if len(orders) > 0 {
+		if err := loadChildren(ctx, tx, orders); err != nil {
+			return OrderPage{}, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return OrderPage{}, fmt.Errorf("commit: %w", err)
+	}
+
+	page.Orders = orders
+	if page.Orders == nil {
+		page.Orders = []model.Order{}
+	}
+	return page, nil
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"L0/internal/model"
+	"unsafe"
+)
+
+// OrderSize — приблизительный объём заказа в памяти: размеры структур плюс
+// содержимое строк. Нужен кешу для лимита по байтам, точность не важна.
+func OrderSize(o model.Order) int64 {
+	n := int64(unsafe.Sizeof(o)) + int64(len(o.Items))*int64(unsafe.Sizeof(model.Item{}))
+	n += strLen(o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.OofShard)
+
+	d := o.Delivery
+	n += strLen(d.OrderID, d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
+
+	p := o.Payment
+	n += strLen(p.OrderID, p.Transaction, p.RequestID, p.Currency, p.Provider, p.Bank)
+
+	for _, it := range o.Items {
+		n += strLen(it.OrderID, it.TrackNumber, it.RID, it.Name, it.Size, it.Brand)
+	}
+	return n
+}
+
+func strLen(ss ...string) int64 {
+	var n int64
+	for _, s := range ss {
+		n += int64(len(s))
+	}
+	return n
+}
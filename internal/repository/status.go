@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"L0/internal/logging"
+	"L0/internal/model"
+	"L0/internal/retry"
+	"L0/internal/tracing"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// UpdateStatus переводит заказ (или позицию, если задан u.RID) в u.Status
+// и пишет запись в историю. Повтор того же события ничего не меняет.
+// Заказа или позиции ещё нет — временная ошибка (событие обогнало заказ),
+// запрещённый переход — постоянная с model.ErrInvalidTransition, rid у
+// нескольких позиций без u.ChrtID — постоянная с ErrAmbiguousItem.
+func (r *Repository) UpdateStatus(ctx context.Context, u model.StatusUpdate) (err error) {
+	ctx, span := tracing.Start(ctx, "repository.UpdateStatus",
+		attribute.String("order.uid", u.OrderUID), attribute.String("order.status", string(u.Status)))
+	defer func() { tracing.End(span, err) }()
+
+	if u.ChangedAt.IsZero() {
+		u.ChangedAt = time.Now().UTC()
+	}
+
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	ps := r.positions(ctx)
+	applied, err := r.claimPositions(ctx, tx, ps)
+	if err != nil {
+		return err
+	}
+	if applied {
+		logging.FromContext(ctx).Info("сообщение уже применено: его оффсет записан в БД")
+		return nil
+	}
+
+	from, chrtID, err := lockStatus(ctx, tx, u)
+	if err != nil {
+		return err
+	}
+	lg := logging.FromContext(ctx).With("rid", u.RID, "from", from, "to", u.Status)
+	if from == u.Status {
+		lg.Info("повтор смены статуса: статус уже установлен")
+		return r.commitPositions(ctx, tx, ps)
+	}
+	if err := model.Transition(from, u.Status); err != nil {
+		return retry.Permanent(err)
+	}
+
+	if u.RID == "" {
+		_, err = tx.Exec(ctx, `UPDATE orders SET status = $2 WHERE order_uid = $1`, u.OrderUID, string(u.Status))
+	} else {
+		code, _ := model.ItemStatusOf(u.Status)
+		_, err = tx.Exec(ctx, `UPDATE order_items SET status = $4 WHERE order_id = $1 AND rid = $2 AND chrt_id = $3`,
+			u.OrderUID, u.RID, chrtID, int(code))
+	}
+	if err != nil {
+		return fmt.Errorf("update status: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO order_status_history (order_id, rid, chrt_id, from_status, to_status, reason, changed_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3::bigint, 0), $4, $5, NULLIF($6, ''), $7)
+	`, u.OrderUID, u.RID, chrtID, string(from), string(u.Status), u.Reason, u.ChangedAt); err != nil {
+		return fmt.Errorf("insert status history: %w", err)
+	}
+	if err := r.storeOffsets(ctx, tx, ps); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	// в кеше заказ со старым статусом — перечитается из БД при следующем запросе
+	r.written(u.OrderUID, func() { r.Cash.Delete(u.OrderUID) })
+	lg.Info("статус изменён")
+	return nil
+}
+
+// lockStatus читает текущий статус заказа или позиции под блокировкой строки.
+// Позиция ищется по (rid, chrt_id) — ключу order_items; без u.ChrtID rid должен
+// быть в заказе единственным. Для позиции возвращает и её chrt_id.
+func lockStatus(ctx context.Context, tx pgx.Tx, u model.StatusUpdate) (model.Status, int64, error) {
+	if u.RID == "" {
+		var st model.Status
+		err := tx.QueryRow(ctx,
+			`SELECT status FROM orders WHERE order_uid = $1 FOR UPDATE`, u.OrderUID).Scan(&st)
+		if err == pgx.ErrNoRows {
+			return "", 0, retry.Transient(fmt.Errorf("%w: %s", ErrNotFound, u.OrderUID))
+		}
+		return st, 0, err
+	}
+
+	type item struct {
+		chrtID int64
+		code   model.ItemStatus
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT chrt_id, status FROM order_items
+		WHERE order_id = $1 AND rid = $2 AND ($3::bigint = 0 OR chrt_id = $3)
+		FOR UPDATE
+	`, u.OrderUID, u.RID, u.ChrtID)
+	if err != nil {
+		return "", 0, err
+	}
+	items, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (item, error) {
+		var it item
+		err := row.Scan(&it.chrtID, &it.code)
+		return it, err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	switch len(items) {
+	case 0:
+		return "", 0, retry.Transient(fmt.Errorf("%w: %s item %s", ErrNotFound, u.OrderUID, u.RID))
+	case 1:
+	default:
+		return "", 0, retry.Permanent(fmt.Errorf("%w: %s has %d items with rid %s, set chrt_id", ErrAmbiguousItem, u.OrderUID, len(items), u.RID))
+	}
+	st, ok := items[0].code.Status()
+	if !ok {
+		return "", 0, retry.Permanent(fmt.Errorf("%w: item %s has unknown status code %d", model.ErrInvalidTransition, u.RID, items[0].code))
+	}
+	return st, items[0].chrtID, nil
+}
+
+// StatusHistory — история статусов заказа и его позиций по порядку записи.
+func (r *Repository) StatusHistory(ctx context.Context, orderUID string) (_ []model.StatusChange, err error) {
+	ctx, span := tracing.Start(ctx, "repository.StatusHistory", attribute.String("order.uid", orderUID))
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := r.Conn.Query(ctx, `
+		SELECT coalesce(rid, ''), coalesce(chrt_id, 0), coalesce(from_status, ''), to_status, coalesce(reason, ''), changed_at
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY id
+	`, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("select status history: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.StatusChange, error) {
+		var c model.StatusChange
+		err := row.Scan(&c.RID, &c.ChrtID, &c.From, &c.To, &c.Reason, &c.ChangedAt)
+		return c, err
+	})
+}
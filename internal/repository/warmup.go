@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"L0/internal/logging"
+	"L0/internal/model"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// warmupBatch — сколько заказов грузим одной пачкой запросов.
+const warmupBatch = 500
+
+// querier — общее у пула и транзакции.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// WarmUp загружает в кеш limit самых свежих (по date_created) заказов.
+// Заказы читаются пачками по warmupBatch: один запрос на orders и по одному
+// на каждую дочернюю таблицу через order_id = ANY($1). При отмене ctx
+// (например, по таймауту) уже загруженное остаётся в кеше.
+func (r *Repository) WarmUp(ctx context.Context, limit int) (int, error) {
+	if limit <= 0 {
+		return 0, nil
+	}
+	start := time.Now()
+	lg := logging.FromContext(ctx)
+	lg.Info("прогрев кеша начат", "limit", limit)
+
+	var (
+		loaded     int
+		lastDate   time.Time
+		lastUID    string
+		firstBatch = true
+	)
+	for loaded < limit {
+		n := min(warmupBatch, limit-loaded)
+
+		var (
+			rows pgx.Rows
+			err  error
+		)
+		if firstBatch {
+			rows, err = r.Conn.Query(ctx, selectOrders+`
+				ORDER BY date_created DESC, order_uid DESC
+				LIMIT $1
+			`, n)
+		} else {
+			rows, err = r.Conn.Query(ctx, selectOrders+`
+				WHERE (date_created, order_uid) < ($1, $2)
+				ORDER BY date_created DESC, order_uid DESC
+				LIMIT $3
+			`, lastDate, lastUID, n)
+		}
+		if err != nil {
+			return loaded, fmt.Errorf("warmup select orders: %w", err)
+		}
+		orders, err := scanOrders(rows)
+		if err != nil {
+			return loaded, fmt.Errorf("warmup: %w", err)
+		}
+		if len(orders) == 0 {
+			break
+		}
+		if err := loadChildren(ctx, r.Conn, orders); err != nil {
+			return loaded, fmt.Errorf("warmup: %w", err)
+		}
+
+		for _, o := range orders {
+			r.Cash.Set(o.OrderUID, o)
+		}
+
+		loaded += len(orders)
+		last := orders[len(orders)-1]
+		lastDate, lastUID, firstBatch = last.DateCreated, last.OrderUID, false
+		lg.Debug("прогрев кеша: пачка загружена", "loaded", loaded, "limit", limit)
+
+		if len(orders) < n {
+			break
+		}
+	}
+
+	lg.Info("прогрев кеша завершён", "loaded", loaded, "duration", time.Since(start))
+	return loaded, nil
+}
+
+const selectOrders = `
+	SELECT order_uid, track_number, entry, locale, internal_signature,
+	       customer_id, delivery_service, shardkey, sm_id,
+	       date_created, oof_shard, status
+	FROM orders
+`
+
+func scanOrders(rows pgx.Rows) ([]model.Order, error) {
+	defer rows.Close()
+
+	var orders []model.Order
+	for rows.Next() {
+		var o model.Order
+		if err := rows.Scan(
+			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID,
+			&o.DateCreated, &o.OofShard, &o.Status,
+		); err != nil {
+			return nil, fmt.Errorf("scan orders: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows orders: %w", err)
+	}
+	return orders, nil
+}
+
+// loadChildren дочитывает delivery, payment и items для пачки заказов
+// тремя запросами вместо трёх на каждый заказ.
+func loadChildren(ctx context.Context, q querier, orders []model.Order) error {
+	ids := make([]string, len(orders))
+	idx := make(map[string]int, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderUID
+		idx[o.OrderUID] = i
+	}
+
+	// deliveries
+	rows, err := q.Query(ctx, `
+		SELECT order_id, name, phone, zip, city, address, region, email
+		FROM deliveries
+		WHERE order_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("select deliveries: %w", err)
+	}
+	for rows.Next() {
+		var d model.Delivery
+		if err := rows.Scan(
+			&d.OrderID, &d.Name, &d.Phone, &d.Zip, &d.City, &d.Address, &d.Region, &d.Email,
+		); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan deliveries: %w", err)
+		}
+		orders[idx[d.OrderID]].Delivery = d
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows deliveries: %w", err)
+	}
+
+	// payments
+	rows, err = q.Query(ctx, `
+		SELECT order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+		       bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
+		FROM payments
+		WHERE order_id = ANY($1)
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("select payments: %w", err)
+	}
+	for rows.Next() {
+		var p model.Payment
+		if err := rows.Scan(
+			&p.OrderID, &p.Transaction, &p.RequestID, &p.Currency, &p.Provider, &p.Amount.Minor,
+			&p.PaymentDT, &p.Bank, &p.DeliveryCost.Minor, &p.GoodsTotal.Minor, &p.CustomFee.Minor,
+		); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan payments: %w", err)
+		}
+		orders[idx[p.OrderID]].Payment = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows payments: %w", err)
+	}
+
+	// items
+	rows, err = q.Query(ctx, `
+		SELECT order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+		       total_price_minor, nm_id, brand, status
+		FROM order_items
+		WHERE order_id = ANY($1)
+		ORDER BY order_id, id
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("select order_items: %w", err)
+	}
+	for rows.Next() {
+		var it model.Item
+		if err := rows.Scan(
+			&it.OrderID, &it.ChrtID, &it.TrackNumber, &it.Price.Minor,
+			&it.RID, &it.Name, &it.Sale, &it.Size,
+			&it.TotalPrice.Minor, &it.NmID, &it.Brand, &it.Status,
+		); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan order_item: %w", err)
+		}
+		i := idx[it.OrderID]
+		orders[i].Items = append(orders[i].Items, it)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("rows order_items: %w", err)
+	}
+	for i := range orders {
+		orders[i].SyncCurrency()
+	}
+	return nil
+}
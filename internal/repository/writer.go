@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"L0/internal/logging"
+	"L0/internal/model"
+	"L0/internal/retry"
+	"L0/internal/tracing"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// writeOutcome — чем закончилась запись заказа.
+type writeOutcome int
+
+const (
+	outcomeInserted    writeOutcome = iota // новый order_uid
+	outcomeDuplicate                       // повторная доставка того же содержимого
+	outcomeKept                            // содержимое другое, оставлена первая версия
+	outcomeOverwritten                     // содержимое другое, заказ перезаписан
+)
+
+func (w writeOutcome) String() string {
+	switch w {
+	case outcomeInserted:
+		return "inserted"
+	case outcomeDuplicate:
+		return "duplicate"
+	case outcomeKept:
+		return "kept"
+	case outcomeOverwritten:
+		return "overwritten"
+	}
+	return "unknown"
+}
+
+// SaveOrder идемпотентно записывает заказ во все четыре таблицы одной
+// транзакцией и после коммита кладёт его в кеш. Расхождение с уже
+// сохранённым заказом разрешается по r.Policy.
+func (r *Repository) SaveOrder(ctx context.Context, o model.Order) (err error) {
+	ctx, span := tracing.Start(ctx, "repository.SaveOrder", attribute.String("order.uid", o.OrderUID))
+	defer func() { tracing.End(span, err) }()
+
+	// дочерним таблицам проставляем id
+	o.Delivery.OrderID = o.OrderUID
+	o.Payment.OrderID = o.OrderUID
+	for i := range o.Items {
+		o.Items[i].OrderID = o.OrderUID
+	}
+
+	hash, err := o.Fingerprint()
+	if err != nil {
+		return retry.Permanent(fmt.Errorf("fingerprint: %w", err))
+	}
+	// новый заказ без статуса — только что принят (после отпечатка: у заказов,
+	// записанных до появления статусов, отпечаток не должен меняться)
+	if o.Status == "" {
+		o.Status = model.StatusCreated
+	}
+
+	//начало транзакции
+	tx, err := r.Conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	ps := r.positions(ctx)
+	applied, err := r.claimPositions(ctx, tx, ps)
+	if err != nil {
+		return err
+	}
+	if applied {
+		logging.FromContext(ctx).Info("сообщение уже применено: его оффсет записан в БД")
+		span.SetAttributes(attribute.Bool("kafka.offset_applied", true))
+		return nil
+	}
+
+	outcome, err := r.writeOrder(ctx, tx, o, hash)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("order.write_outcome", outcome.String()))
+	if e, ok := orderEvent(o, hash, outcome); ok {
+		if err := r.enqueueEvents(ctx, tx, []model.OrderEvent{e}); err != nil {
+			return err
+		}
+	}
+	if err := r.storeOffsets(ctx, tx, ps); err != nil {
+		return err
+	}
+
+	// commit
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+
+	r.written(o.OrderUID, func() {
+		if r.NotFound != nil {
+			r.NotFound.Delete(o.OrderUID)
+		}
+		switch outcome {
+		case outcomeInserted:
+			r.Cash.Set(o.OrderUID, o)
+		default:
+			// kept: в БД осталась первая версия — кеш не должен держать отброшенную;
+			// duplicate, overwritten: статусы в БД могли уйти вперёд от сообщения
+			r.Cash.Delete(o.OrderUID)
+		}
+	})
+	return nil
+}
+
+// resolveConflict решает по r.Policy, что делать с заказом, который уже
+// записан с отпечатком stored.
+func (r *Repository) resolveConflict(ctx context.Context, uid string, stored *string, hash string) (writeOutcome, error) {
+	if stored != nil && *stored == hash {
+		logging.FromContext(ctx).Info("повторная доставка: заказ уже записан с тем же содержимым")
+		return outcomeDuplicate, nil
+	}
+
+	switch r.Policy {
+	case PolicyKeepFirst:
+		logging.FromContext(ctx).Warn("заказ уже записан с другим содержимым, оставляю первую версию")
+		return outcomeKept, nil
+	case PolicyReject:
+		return 0, retry.Permanent(fmt.Errorf("%w: order_uid %s", ErrPayloadConflict, uid))
+	}
+	logging.FromContext(ctx).Warn("заказ уже записан с другим содержимым, перезаписываю")
+	return outcomeOverwritten, nil
+}
+
+func (r *Repository) writeOrder(ctx context.Context, tx pgx.Tx, o model.Order, hash string) (writeOutcome, error) {
+	outcome := outcomeInserted
+
+	// orders: новый order_uid вставляется, существующий не трогаем до решения по политике
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, payload_hash, status
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		ON CONFLICT (order_uid) DO NOTHING
+	`,
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, hash, string(o.Status),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("orders insert: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		// начало истории статусов
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO order_status_history (order_id, to_status, changed_at) VALUES ($1, $2, $3)
+		`, o.OrderUID, string(o.Status), o.DateCreated); err != nil {
+			return 0, fmt.Errorf("status history insert: %w", err)
+		}
+	}
+
+	if tag.RowsAffected() == 0 {
+		// заказ уже есть: сравниваем отпечатки под блокировкой строки
+		var stored *string
+		if err := tx.QueryRow(ctx,
+			`SELECT payload_hash FROM orders WHERE order_uid = $1 FOR UPDATE`, o.OrderUID,
+		).Scan(&stored); err != nil {
+			return 0, fmt.Errorf("orders lock: %w", err)
+		}
+		outcome, err := r.resolveConflict(ctx, o.OrderUID, stored, hash)
+		if err != nil || outcome != outcomeOverwritten {
+			return outcome, err
+		}
+
+		// статус не перезаписываем: им управляют события смены статуса
+		if _, err := tx.Exec(ctx, `
+			UPDATE orders SET
+				track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+				customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9,
+				date_created = $10, oof_shard = $11, payload_hash = $12
+			WHERE order_uid = $1
+		`,
+			o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+			o.CustomerID, o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, hash,
+		); err != nil {
+			return 0, fmt.Errorf("orders update: %w", err)
+		}
+		outcome = outcomeOverwritten
+	}
+
+	// UPSERT deliveries (1:1, PK order_id)
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO deliveries (order_id, name, phone, zip, city, address, region, email)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (order_id) DO UPDATE SET
+			name = excluded.name, phone = excluded.phone, zip = excluded.zip, city = excluded.city,
+			address = excluded.address, region = excluded.region, email = excluded.email
+	`,
+		o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
+		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
+	); err != nil {
+		return 0, fmt.Errorf("deliveries upsert: %w", err)
+	}
+
+	// UPSERT payments по UNIQUE(transaction); при перезаписи транзакция заказа могла смениться
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM payments WHERE order_id = $1 AND transaction <> $2`, o.OrderUID, o.Payment.Transaction,
+	); err != nil {
+		return 0, fmt.Errorf("payments cleanup: %w", err)
+	}
+	tag, err = tx.Exec(ctx, `
+		INSERT INTO payments (
+			order_id, transaction, request_id, currency, provider, amount_minor, payment_dt,
+			bank, delivery_cost_minor, goods_total_minor, custom_fee_minor
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		ON CONFLICT (transaction) DO UPDATE SET
+			request_id = excluded.request_id, currency = excluded.currency, provider = excluded.provider,
+			amount_minor = excluded.amount_minor, payment_dt = excluded.payment_dt, bank = excluded.bank,
+			delivery_cost_minor = excluded.delivery_cost_minor, goods_total_minor = excluded.goods_total_minor,
+			custom_fee_minor = excluded.custom_fee_minor
+		WHERE payments.order_id = excluded.order_id
+	`,
+		o.OrderUID, o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
+		o.Payment.Amount.Minor, o.Payment.PaymentDT, o.Payment.Bank,
+		o.Payment.DeliveryCost.Minor, o.Payment.GoodsTotal.Minor, o.Payment.CustomFee.Minor,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("payments upsert: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, retry.Permanent(fmt.Errorf("payments upsert: transaction %s belongs to another order", o.Payment.Transaction))
+	}
+
+	// UPSERT order_items по UNIQUE(order_id, chrt_id, rid); статус существующей
+	// позиции не трогаем — как и у заказа
+	chrtIDs := make([]int64, 0, len(o.Items))
+	rids := make([]string, 0, len(o.Items))
+	for _, it := range o.Items {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO order_items (
+				order_id, chrt_id, track_number, price_minor, rid, name, sale, size,
+				total_price_minor, nm_id, brand, status
+			) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+			ON CONFLICT (order_id, chrt_id, rid) DO UPDATE SET
+				track_number = excluded.track_number, price_minor = excluded.price_minor, name = excluded.name,
+				sale = excluded.sale, size = excluded.size, total_price_minor = excluded.total_price_minor,
+				nm_id = excluded.nm_id, brand = excluded.brand
+		`,
+			o.OrderUID, it.ChrtID, it.TrackNumber, it.Price.Minor, it.RID, it.Name, it.Sale, it.Size,
+			it.TotalPrice.Minor, it.NmID, it.Brand, int(it.Status),
+		); err != nil {
+			return 0, fmt.Errorf("items upsert: %w", err)
+		}
+		chrtIDs = append(chrtIDs, it.ChrtID)
+		rids = append(rids, it.RID)
+	}
+	// товары, которых нет в новой версии заказа, удаляем
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM order_items
+		WHERE order_id = $1
+		  AND (chrt_id, rid) NOT IN (SELECT * FROM unnest($2::bigint[], $3::text[]))
+	`, o.OrderUID, chrtIDs, rids); err != nil {
+		return 0, fmt.Errorf("items cleanup: %w", err)
+	}
+	return outcome, nil
+}
@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// permanent помечает ошибку как неповторяемую.
+type permanent struct {
+	err error
+}
+
+func (e *permanent) Error() string   { return e.err.Error() }
+func (e *permanent) Unwrap() error   { return e.err }
+func (e *permanent) Permanent() bool { return true }
+
+// Permanent явно помечает ошибку как постоянную: повтор не поможет.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanent{err: err}
+}
+
+// transient помечает ошибку как временную.
+type transient struct {
+	err error
+}
+
+func (e *transient) Error() string   { return e.err.Error() }
+func (e *transient) Unwrap() error   { return e.err }
+func (e *transient) Transient() bool { return true }
+
+// Transient явно помечает ошибку как временную: данных ещё нет, но могут
+// появиться (например, смена статуса пришла раньше самого заказа).
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &transient{err: err}
+}
+
+// IsRetriable решает, имеет ли смысл повторить операцию.
+//
+// Повторяем: ошибки, помеченные Transient() == true, обрывы соединения
+// и сетевые таймауты, недоступность сервера, serialization failure (40001),
+// deadlock (40P01), нехватку ресурсов (53*), остановку/рестарт Postgres
+// (57P0*), lock_not_available (55P03) и context.DeadlineExceeded отдельной
+// попытки.
+//
+// Не повторяем: ошибки, помеченные Permanent() == true (в т.ч. этапы decode/validate
+// из dlq), нарушения ограничений (23*), ошибки данных (22*), отмену контекста
+// и всё, что не распознано.
+func IsRetriable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var p interface{ Permanent() bool }
+	if errors.As(err, &p) && p.Permanent() {
+		return false
+	}
+
+	var t interface{ Transient() bool }
+	if errors.As(err, &t) && t.Transient() {
+		return true
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return retriableSQLState(pgErr.Code)
+	}
+
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return true
+	}
+	if pgconn.SafeToRetry(err) || pgconn.Timeout(err) {
+		return true
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	return false
+}
+
+func retriableSQLState(code string) bool {
+	switch code {
+	case "40001", // serialization_failure
+		"40P01", // deadlock_detected
+		"55P03", // lock_not_available
+		"57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03": // cannot_connect_now
+		return true
+	}
+	if len(code) < 2 {
+		return false
+	}
+	switch code[:2] {
+	case "08", // connection_exception
+		"53": // insufficient_resources
+		return true
+	}
+	// 22 data_exception, 23 integrity_constraint_violation и прочее — постоянные
+	return false
+}
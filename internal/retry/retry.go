@@ -0,0 +1,64 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Policy — бюджет повторов и параметры экспоненциальной задержки.
+type Policy struct {
+	MaxAttempts int           // всего попыток, включая первую
+	BaseDelay   time.Duration // задержка перед второй попыткой
+	MaxDelay    time.Duration // потолок задержки
+	Jitter      float64       // доля случайного разброса задержки, 0..1
+}
+
+// Backoff возвращает задержку после неудачной попытки attempt (с 1):
+// BaseDelay * 2^(attempt-1), не больше MaxDelay, с разбросом ±Jitter.
+func (p Policy) Backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			d = p.MaxDelay
+			break
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		j := min(p.Jitter, 1)
+		// равномерно в [d*(1-j), d*(1+j)]
+		d = time.Duration(float64(d) * (1 - j + 2*j*rand.Float64()))
+	}
+	return d
+}
+
+// Do вызывает fn, пока она возвращает повторяемую ошибку и не исчерпан бюджет.
+// Возвращает число сделанных попыток и последнюю ошибку. Если ctx отменён
+// во время ожидания, возвращается последняя ошибка fn — решать, что с ней
+// делать, вызывающему (обычно ctx.Err() != nil означает остановку сервиса).
+func Do(ctx context.Context, p Policy, fn func(ctx context.Context, attempt int) error) (int, error) {
+	maxAttempts := max(p.MaxAttempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx, attempt)
+		if err == nil || !IsRetriable(err) || attempt >= maxAttempts {
+			return attempt, err
+		}
+
+		t := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return attempt, err
+		case <-t.C:
+		}
+	}
+}
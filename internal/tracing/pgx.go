@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer — pgx.QueryTracer, открывающий спан на каждый запрос, включая
+// BEGIN/COMMIT транзакций. Подключается через ConnConfig.Tracer пула.
+type QueryTracer struct{}
+
+var _ pgx.QueryTracer = QueryTracer{}
+
+func (QueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	// запросы вне трассы (прогрев кеша, health-check) не порождают новых трасс
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	ctx, span := Start(ctx, spanName(data.SQL),
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.query.text", compact(data.SQL)),
+	)
+	// свой ключ, чтобы TraceQueryEnd не закрыл по ошибке родительский спан
+	return context.WithValue(ctx, querySpanKey{}, span)
+}
+
+type querySpanKey struct{}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span, ok := ctx.Value(querySpanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	End(span, data.Err)
+}
+
+var tableRe = regexp.MustCompile(`(?is)^\s*(insert\s+into|update|delete\s+from|select\b.*?\bfrom)\s+([a-z_][a-z0-9_.]*)`)
+
+// spanName — "INSERT orders", "SELECT order_items", "COMMIT": по имени
+// спана видно, какая таблица сколько заняла.
+func spanName(sql string) string {
+	if m := tableRe.FindStringSubmatch(sql); m != nil {
+		op := strings.ToUpper(strings.Fields(m[1])[0])
+		return op + " " + m[2]
+	}
+	if f := strings.Fields(sql); len(f) > 0 {
+		return strings.ToUpper(f[0])
+	}
+	return "SQL"
+}
+
+func compact(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
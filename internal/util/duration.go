@@ -1,7 +1,8 @@
 package util
 
 import (
-	"log"
+	"L0/internal/metrics"
+	"log/slog"
 	"time"
 )
 
@@ -9,6 +10,10 @@ func Track(msg string) (string, time.Time) {
 	return msg, time.Now()
 }
 
+// Duration пишет время операции в debug-лог и в гистограмму
+// l0_operation_duration_seconds{operation=msg}.
 func Duration(msg string, start time.Time) {
-	log.Printf("%v: %v\n", msg, time.Since(start))
+	d := time.Since(start)
+	metrics.OperationDuration.WithLabelValues(msg).Observe(d.Seconds())
+	slog.Debug("операция завершена", "operation", msg, "duration", d)
 }